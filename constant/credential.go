@@ -11,3 +11,16 @@ const (
 	CredentialStatusClaimed   = "CLAIMED"
 	CredentialStatusSuspended = "SUSPEND"
 )
+
+// Status types
+const (
+	CredentialStatusList2017 = "CredentialStatusList2017"
+	StatusList2021Entry      = "StatusList2021Entry"
+	RevocationList2020Status = "RevocationList2020Status"
+)
+
+// Status list purposes
+const (
+	StatusPurposeRevocation = "revocation"
+	StatusPurposeSuspension = "suspension"
+)
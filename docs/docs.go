@@ -677,6 +677,37 @@ var doc = `{
                 }
             }
         },
+        "models.CheckReport": {
+            "type": "object",
+            "properties": {
+                "check": {
+                    "type": "string",
+                    "example": "credentialStatus"
+                },
+                "code": {
+                    "type": "string",
+                    "example": "STATUS_REVOKED"
+                },
+                "duration": {
+                    "type": "integer",
+                    "example": 1500000
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "path": {
+                    "type": "string",
+                    "example": "$.credentialStatus"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "failed"
+                }
+            }
+        },
         "models.Constraints": {
             "type": "object",
             "properties": {
@@ -686,8 +717,21 @@ var doc = `{
                         "$ref": "#/definitions/models.Field"
                     }
                 },
+                "is_holder": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SubjectDirective"
+                    }
+                },
                 "limit_disclosure": {
-                    "type": "boolean"
+                    "description": "Boolean on PE v1, preference (required or preferred) since PE v2",
+                    "type": "string"
+                },
+                "same_subject": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SubjectDirective"
+                    }
                 },
                 "subject_is_holder": {
                     "type": "string"
@@ -736,6 +780,12 @@ var doc = `{
                         "$ref": "#/definitions/models.Purpose"
                     }
                 },
+                "represented_subjects": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "template_id": {
                     "type": "string"
                 },
@@ -826,6 +876,37 @@ var doc = `{
                 },
                 "path": {
                     "type": "string"
+                },
+                "path_nested": {
+                    "description": "Evaluated on the document found at Path, decoded with its Format",
+                    "$ref": "#/definitions/models.Descriptor"
+                }
+            }
+        },
+        "models.DescriptorReport": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CheckReport"
+                    }
+                },
+                "credentialId": {
+                    "type": "string",
+                    "example": "cred:gatc:exampleABC123"
+                },
+                "descriptorId": {
+                    "type": "string",
+                    "example": "banking_input"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "$.verifiableCredential[0]"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "passed"
                 }
             }
         },
@@ -869,6 +950,15 @@ var doc = `{
                 "filter": {
                     "$ref": "#/definitions/models.Filter"
                 },
+                "id": {
+                    "type": "string"
+                },
+                "intent_to_retain": {
+                    "type": "boolean"
+                },
+                "optional": {
+                    "type": "boolean"
+                },
                 "path": {
                     "type": "array",
                     "items": {
@@ -890,6 +980,9 @@ var doc = `{
             ],
             "properties": {
                 "const": {},
+                "contains": {
+                    "$ref": "#/definitions/models.Filter"
+                },
                 "enum": {
                     "type": "array",
                     "items": {}
@@ -944,13 +1037,15 @@ var doc = `{
         "models.InputDescriptor": {
             "type": "object",
             "required": [
-                "id",
-                "schema"
+                "id"
             ],
             "properties": {
                 "constraints": {
                     "$ref": "#/definitions/models.Constraints"
                 },
+                "format": {
+                    "$ref": "#/definitions/models.Format"
+                },
                 "group": {
                     "type": "array",
                     "items": {
@@ -960,6 +1055,21 @@ var doc = `{
                 "id": {
                     "type": "string"
                 },
+                "level_of_assurance": {
+                    "type": "string",
+                    "enum": [
+                        "low",
+                        "substantial",
+                        "high"
+                    ]
+                },
+                "mechanism": {
+                    "type": "string",
+                    "enum": [
+                        "authn_factor",
+                        "wallet_auth"
+                    ]
+                },
                 "metadata": {
                     "type": "string"
                 },
@@ -975,6 +1085,10 @@ var doc = `{
                     "items": {
                         "$ref": "#/definitions/models.Schema"
                     }
+                },
+                "trust_level": {
+                    "type": "integer",
+                    "minimum": 0
                 }
             }
         },
@@ -1033,9 +1147,17 @@ var doc = `{
                 "input_descriptors"
             ],
             "properties": {
+                "challenge": {
+                    "description": "Nonce of the exchange that the proof of the presentation must include",
+                    "type": "string"
+                },
                 "dataAgreement": {
                     "$ref": "#/definitions/models.DataAgreementRef"
                 },
+                "domain": {
+                    "description": "Domain of the verifier that the proof of the presentation must include",
+                    "type": "string"
+                },
                 "format": {
                     "$ref": "#/definitions/models.Format"
                 },
@@ -1215,6 +1337,24 @@ var doc = `{
                 }
             }
         },
+        "models.SubjectDirective": {
+            "type": "object",
+            "required": [
+                "directive",
+                "field_id"
+            ],
+            "properties": {
+                "directive": {
+                    "type": "string"
+                },
+                "field_id": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
         "models.SubmissionRequirement": {
             "type": "object",
             "required": [
@@ -1363,6 +1503,12 @@ var doc = `{
                         "['proof']"
                     ]
                 },
+                "descriptors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DescriptorReport"
+                    }
+                },
                 "errors": {
                     "type": "array",
                     "items": {
@@ -31,7 +31,7 @@ require (
 	github.com/piprate/json-gold v0.4.1-0.20210813112359-33b90c4ca86c // indirect
 	github.com/spf13/viper v1.8.1 // indirect
 	github.com/stretchr/testify v1.7.0
-	github.com/swaggo/swag v1.7.8 // indirect
+	github.com/swaggo/swag v1.7.8
 	github.com/ucarion/jcs v0.1.2 // indirect
 	github.com/urfave/cli v1.22.5 // indirect
 	github.com/valyala/fasttemplate v1.2.1
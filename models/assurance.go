@@ -0,0 +1,89 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// LevelOfAssurance is the eIDAS level of assurance of the identity proofing of the subject of a credential
+type LevelOfAssurance string
+
+const (
+	LoALow         LevelOfAssurance = "low"
+	LoASubstantial LevelOfAssurance = "substantial"
+	LoAHigh        LevelOfAssurance = "high"
+)
+
+// Presence of the subject or its identity document during the identity proofing, as stated by the evidence
+const (
+	PresencePhysical = "Physical"
+	PresenceDigital  = "Digital"
+)
+
+// DocumentVerification is the type of evidence of an identity proofing based on an identity document
+const DocumentVerification = "DocumentVerification"
+
+var assuranceRanks = map[LevelOfAssurance]int{
+	LoALow:         1,
+	LoASubstantial: 2,
+	LoAHigh:        3,
+}
+
+// IsValid tells if the level is one of the eIDAS levels of assurance
+func (l LevelOfAssurance) IsValid() bool {
+	_, ok := assuranceRanks[l]
+	return ok
+}
+
+// Satisfies tells if the level is equal or higher than the required one. No level satisfies an unknown requirement.
+func (l LevelOfAssurance) Satisfies(required LevelOfAssurance) bool {
+	rank, ok := assuranceRanks[l]
+	return ok && required.IsValid() && rank >= assuranceRanks[required]
+}
+
+// LevelOfAssurance derives the level reached by the identity proofing described by the evidence at the given time.
+// Verifying an identity document with both the subject and the document physically present reaches a high level,
+// and with both present either physically or digitally, a substantial one. Any other evidence, or a document
+// expired at the given time, only reaches a low level. Credentials without evidence reach no level at all.
+func (e *Evidence) LevelOfAssurance(at time.Time) LevelOfAssurance {
+	if e == nil {
+		return ""
+	}
+	if !e.verifiesDocument() || e.EvidenceDocument.isExpired(at) {
+		return LoALow
+	}
+	subject, document := presence(e.SubjectPresence), presence(e.DocumentPresence)
+	switch {
+	case subject == PresencePhysical && document == PresencePhysical:
+		return LoAHigh
+	case subject != "" && document != "":
+		return LoASubstantial
+	}
+	return LoALow
+}
+
+func (e *Evidence) verifiesDocument() bool {
+	if e.EvidenceDocument == nil {
+		return false
+	}
+	for _, t := range e.Type {
+		if t == DocumentVerification {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *EvidenceDocument) isExpired(at time.Time) bool {
+	return d.DocumentExpirationDate != nil && d.DocumentExpirationDate.Before(at)
+}
+
+// presence normalizes the presence stated by the evidence, returning an empty string for unknown values
+func presence(value string) string {
+	for _, known := range []string{PresencePhysical, PresenceDigital} {
+		if strings.EqualFold(value, known) {
+			return known
+		}
+	}
+	return ""
+}
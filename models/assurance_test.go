@@ -0,0 +1,45 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gataca-io/vui-core/testdata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvidence_LevelOfAssurance(t *testing.T) {
+	var vc VerifiableCredential
+	assert.NoError(t, json.Unmarshal([]byte(testdata.GatacaCredentialEvidence), &vc))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, LoAHigh, vc.Evidence.LevelOfAssurance(now))
+
+	tests := []struct {
+		name     string
+		evidence *Evidence
+		level    LevelOfAssurance
+	}{
+		{"No evidence", nil, ""},
+		{"Remote verification", &Evidence{Type: []string{DocumentVerification}, SubjectPresence: "Digital", DocumentPresence: "Physical", EvidenceDocument: vc.Evidence.EvidenceDocument}, LoASubstantial},
+		{"Unknown presence", &Evidence{Type: []string{DocumentVerification}, DocumentPresence: "physical", EvidenceDocument: vc.Evidence.EvidenceDocument}, LoALow},
+		{"No document", &Evidence{Type: []string{DocumentVerification}, SubjectPresence: "Physical", DocumentPresence: "Physical"}, LoALow},
+		{"Not a document verification", &Evidence{Type: []string{"EmailVerification"}, SubjectPresence: "Physical", DocumentPresence: "Physical", EvidenceDocument: vc.Evidence.EvidenceDocument}, LoALow},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.level, test.evidence.LevelOfAssurance(now))
+		})
+	}
+
+	expired := now.AddDate(10, 0, 0)
+	assert.Equal(t, LoALow, vc.Evidence.LevelOfAssurance(expired))
+}
+
+func TestLevelOfAssurance_Satisfies(t *testing.T) {
+	assert.True(t, LoAHigh.Satisfies(LoASubstantial))
+	assert.True(t, LoASubstantial.Satisfies(LoASubstantial))
+	assert.False(t, LoALow.Satisfies(LoASubstantial))
+	assert.False(t, LevelOfAssurance("").Satisfies(LoALow))
+	assert.False(t, LoAHigh.Satisfies("maximum"))
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 )
@@ -8,7 +9,9 @@ import (
 type VerifiablePresentation struct {
 	Context                *SSIContext             `json:"@context,omitempty" description:"Context for JSON-LD"`
 	DataAgreementId        string                  `json:"data_agreement_id,omitempty" example:"da:gatc:ehgiuwg39487wq9gf7a47af37" description:"Id of the data agreement supporting this exchange"`
+	ExpirationDate         *TimeWithFormat         `json:"expirationDate,omitempty" swaggertype:"string" example:"2019-10-01T12:12:15.999Z" description:"Timestamp of expiration of the presentation"`
 	Holder                 *string                 `json:"holder,omitempty" example:"did:gatc:example1234567" description:"DID of the Holder of the credentials"`
+	IssuanceDate           *TimeWithFormat         `json:"issuanceDate,omitempty" swaggertype:"string" example:"2019-10-01T12:12:05.999Z" description:"Timestamp from which the presentation is valid"`
 	PresentationSubmission *PresentationSubmission `json:"presentation_submission,omitempty" description:"Presentation submission according to DIF PE"`
 	Proof                  *SSIProof               `json:"proof,omitempty" description:"Proofs to verify the presentation"`
 	Type                   []string                `json:"type,omitempty" example:"VerifiablePresentation" description:"Definition of the format of the presentation"`
@@ -47,6 +50,21 @@ func (v *VerifiablePresentation) ToPresentation() *VerifiablePresentation {
 	return v
 }
 
+// UnmarshalJSON accepts presentations encoded as JWT besides the JSON-LD ones
+func (v *VerifiablePresentation) UnmarshalJSON(jsonData []byte) error {
+	var token string
+	if bytes.HasPrefix(bytes.TrimSpace(jsonData), []byte(`"`)) && json.Unmarshal(jsonData, &token) == nil {
+		vp, err := ParseJWTPresentation(token)
+		if err != nil {
+			return err
+		}
+		*v = *vp
+		return nil
+	}
+	type presentation VerifiablePresentation
+	return json.Unmarshal(jsonData, (*presentation)(v))
+}
+
 type VerifiableCredential struct {
 	Context           *SSIContext             `json:"@context,omitempty" description:"Context for JSON-LD"`
 	CredentialSchema  *CredentialSchema       `json:"credentialSchema,omitempty" swaggertype:"object,string" description:"Definition to retrieve the schema of the credential"` //Reusing the CredentialStatusType coz its the same struct here
@@ -79,6 +97,21 @@ func (v *VerifiableCredential) SetProofChain(proof *[]Proof) {
 	//No support
 }
 
+// UnmarshalJSON accepts credentials encoded as JWT besides the JSON-LD ones
+func (v *VerifiableCredential) UnmarshalJSON(jsonData []byte) error {
+	var token string
+	if bytes.HasPrefix(bytes.TrimSpace(jsonData), []byte(`"`)) && json.Unmarshal(jsonData, &token) == nil {
+		vc, err := ParseJWTCredential(token)
+		if err != nil {
+			return err
+		}
+		*v = *vc
+		return nil
+	}
+	type credential VerifiableCredential
+	return json.Unmarshal(jsonData, (*credential)(v))
+}
+
 func (v *VerifiableCredential) GetContext() *SSIContext {
 	return v.Context
 }
@@ -119,8 +152,13 @@ type EvidenceDocument struct {
 }
 
 type CredentialStatus struct {
-	Id   string `json:"id,omitempty" example:"https://issuer.domain.com/cred:example:zzzzzzzzzz" description:"URI to query the current credential status"`
-	Type string `json:"type,omitempty" example:"CredentialStatusList2017" description:"Credential Status Protocol definition"`
+	Id                       string `json:"id,omitempty" example:"https://issuer.domain.com/cred:example:zzzzzzzzzz" description:"URI to query the current credential status"`
+	Type                     string `json:"type,omitempty" example:"CredentialStatusList2017" description:"Credential Status Protocol definition"`
+	StatusPurpose            string `json:"statusPurpose,omitempty" example:"revocation" description:"Purpose of the status list entry: revocation or suspension"`
+	StatusListIndex          string `json:"statusListIndex,omitempty" example:"94567" description:"Position of the credential in the StatusList2021 bitstring"`
+	StatusListCredential     string `json:"statusListCredential,omitempty" example:"https://issuer.domain.com/status/3" description:"URI of the StatusList2021 credential"`
+	RevocationListIndex      string `json:"revocationListIndex,omitempty" example:"94567" description:"Position of the credential in the RevocationList2020 bitstring"`
+	RevocationListCredential string `json:"revocationListCredential,omitempty" example:"https://issuer.domain.com/status/3" description:"URI of the RevocationList2020 credential"`
 }
 
 type CredentialSchema = CredentialStatus
@@ -5,6 +5,7 @@ type DataAgreement struct {
 	DataHolder           string          `json:"data_holder,omitempty"`
 	DataReceiver         DataReceiver    `json:"data_receiver,omitempty"`
 	DataSubject          string          `json:"data_subject,omitempty"`
+	RepresentedSubjects  []string        `json:"represented_subjects,omitempty"`
 	Dpia                 *Dpia           `json:"dpia,omitempty"`
 	Event                []Event         `json:"event,omitempty"`
 	ID                   string          `json:"id"`
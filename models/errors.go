@@ -28,23 +28,101 @@ var (
 	ErrInvalidDIDMethod    = errors.New("cannot register DIDs with non GATC method")
 
 	//Validations
-	ErrNotMatch            = errors.New("presentation response does not match")
-	ErrRepeatedClaim       = errors.New("required claim is found repeated")
-	ErrInvalidFormat       = errors.New("document is not valid json")
-	ErrUnwantedClaim       = errors.New("found unrequested claim")
-	ErrMissingClaim        = errors.New("required claim is missing")
-	ErrMissingConstraint   = errors.New("required constraint couldn't be satisfied")
-	ErrMissingRequirement  = errors.New("submission requirement couldn't be satisfied")
-	ErrMissingSecondFactor = errors.New("required second factor is missing")
-	ErrMissingSFProof      = errors.New("second factor proof is missing")
-	ErrSFValidation        = errors.New("second factor could not be validated")
-	ErrInvalidContext      = errors.New("document linked data context couldn't be validated")
-	ErrMissingVerifiable   = errors.New("missing object to verify")
-	ErrSessionRequested    = errors.New("your session is already in use")
-	ErrConsentValidation   = errors.New("claim consent validation fail")
-	ErrCredentialsNotMatch = errors.New("credentials requested are not avaliable in tenant")
-	ErrRenewDisallowed     = errors.New("renew service is not activated")
+	ErrNotMatch                = errors.New("presentation response does not match")
+	ErrRepeatedClaim           = errors.New("required claim is found repeated")
+	ErrInvalidFormat           = errors.New("document is not valid json")
+	ErrUnwantedClaim           = errors.New("found unrequested claim")
+	ErrMissingClaim            = errors.New("required claim is missing")
+	ErrMissingConstraint       = errors.New("required constraint couldn't be satisfied")
+	ErrMissingRequirement      = errors.New("submission requirement couldn't be satisfied")
+	ErrMissingSecondFactor     = errors.New("required second factor is missing")
+	ErrMissingSFProof          = errors.New("second factor proof is missing")
+	ErrSFValidation            = errors.New("second factor could not be validated")
+	ErrInvalidContext          = errors.New("document linked data context couldn't be validated")
+	ErrMissingVerifiable       = errors.New("missing object to verify")
+	ErrSessionRequested        = errors.New("your session is already in use")
+	ErrConsentValidation       = errors.New("claim consent validation fail")
+	ErrCredentialsNotMatch     = errors.New("credentials requested are not avaliable in tenant")
+	ErrRenewDisallowed         = errors.New("renew service is not activated")
+	ErrLimitDisclosure         = errors.New("credential discloses more claims than requested")
+	ErrHolderBinding           = errors.New("credential subject is not the holder of the presentation")
+	ErrCredentialExpired       = errors.New("credential has expired")
+	ErrCredentialNotYetValid   = errors.New("credential is not valid yet")
+	ErrPresentationNotYetValid = errors.New("presentation is not valid yet")
+	ErrPresentationExpired     = errors.New("presentation has expired")
+	ErrInvalidSignature        = errors.New("signature couldn't be verified")
+	ErrUnsupportedAlgorithm    = errors.New("signature algorithm is not supported")
+	ErrUnsupportedFormat       = errors.New("proof format is not accepted by the definition")
+	ErrInvalidChallenge        = errors.New("presentation challenge doesn't match the exchange")
+	ErrInvalidDomain           = errors.New("presentation domain doesn't match the exchange")
+	ErrChallengeUsed           = errors.New("presentation challenge has already been used")
+	ErrSubjectMismatch         = errors.New("credentials are not about the same subject")
+	ErrInvalidDefinition       = errors.New("presentation definition has authoring errors")
+	ErrInsufficientAssurance   = errors.New("credential evidence doesn't reach the required level of assurance")
+	ErrUntrustedIssuer         = errors.New("issuer is not accredited for the credential")
+	ErrSchemaNotAvailable      = errors.New("json schema of the credential is not available")
 
 	//Status
-	ErrStatusNotValid = errors.New("credential status not valid")
+	ErrStatusNotValid    = errors.New("credential status not valid")
+	ErrStatusPending     = errors.New("credential issuance is pending")
+	ErrStatusRevoked     = errors.New("credential has been revoked")
+	ErrStatusDeleted     = errors.New("credential has been deleted")
+	ErrStatusExpired     = errors.New("credential status is expired")
+	ErrStatusInvalid     = errors.New("credential has been invalidated")
+	ErrStatusClaimed     = errors.New("credential has been claimed but not issued")
+	ErrStatusSuspended   = errors.New("credential is suspended")
+	ErrUnsupportedStatus = errors.New("credential status type is not supported")
 )
+
+var errorCodes = map[error]string{
+	ErrDIDNotAvailable:         "DID_NOT_AVAILABLE",
+	ErrMissingKey:              "MISSING_KEY",
+	ErrNotMatch:                "NOT_MATCH",
+	ErrRepeatedClaim:           "REPEATED_CLAIM",
+	ErrInvalidFormat:           "INVALID_FORMAT",
+	ErrUnwantedClaim:           "UNWANTED_CLAIM",
+	ErrMissingClaim:            "MISSING_CLAIM",
+	ErrMissingConstraint:       "MISSING_CONSTRAINT",
+	ErrMissingRequirement:      "MISSING_REQUIREMENT",
+	ErrMissingSecondFactor:     "MISSING_SECOND_FACTOR",
+	ErrMissingSFProof:          "MISSING_SECOND_FACTOR_PROOF",
+	ErrSFValidation:            "SECOND_FACTOR_VALIDATION",
+	ErrInvalidContext:          "INVALID_CONTEXT",
+	ErrMissingVerifiable:       "MISSING_VERIFIABLE",
+	ErrConsentValidation:       "CONSENT_VALIDATION",
+	ErrLimitDisclosure:         "LIMIT_DISCLOSURE",
+	ErrHolderBinding:           "HOLDER_BINDING",
+	ErrCredentialExpired:       "CREDENTIAL_EXPIRED",
+	ErrCredentialNotYetValid:   "CREDENTIAL_NOT_YET_VALID",
+	ErrPresentationNotYetValid: "PRESENTATION_NOT_YET_VALID",
+	ErrPresentationExpired:     "PRESENTATION_EXPIRED",
+	ErrInvalidSignature:        "INVALID_SIGNATURE",
+	ErrUnsupportedAlgorithm:    "UNSUPPORTED_ALGORITHM",
+	ErrUnsupportedFormat:       "UNSUPPORTED_FORMAT",
+	ErrInvalidChallenge:        "INVALID_CHALLENGE",
+	ErrInvalidDomain:           "INVALID_DOMAIN",
+	ErrChallengeUsed:           "CHALLENGE_USED",
+	ErrSubjectMismatch:         "SUBJECT_MISMATCH",
+	ErrInvalidDefinition:       "INVALID_DEFINITION",
+	ErrInsufficientAssurance:   "INSUFFICIENT_ASSURANCE",
+	ErrUntrustedIssuer:         "UNTRUSTED_ISSUER",
+	ErrSchemaNotAvailable:      "SCHEMA_NOT_AVAILABLE",
+	ErrStatusNotValid:          "STATUS_NOT_VALID",
+	ErrStatusPending:           "STATUS_PENDING",
+	ErrStatusRevoked:           "STATUS_REVOKED",
+	ErrStatusDeleted:           "STATUS_DELETED",
+	ErrStatusExpired:           "STATUS_EXPIRED",
+	ErrStatusInvalid:           "STATUS_INVALID",
+	ErrStatusClaimed:           "STATUS_CLAIMED",
+	ErrStatusSuspended:         "STATUS_SUSPENDED",
+	ErrUnsupportedStatus:       "UNSUPPORTED_STATUS",
+}
+
+// ErrorCode returns a stable, machine readable code for the errors of the validations.
+// Errors raised by other components, like network or cryptographic libraries, are reported as UNKNOWN.
+func ErrorCode(err error) string {
+	if code, ok := errorCodes[err]; ok {
+		return code
+	}
+	return "UNKNOWN"
+}
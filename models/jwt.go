@@ -0,0 +1,198 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const (
+	// ProofTypeJWT identifies the proofs of credentials and presentations received as compact JWS
+	ProofTypeJWT = "JwtProof2020"
+)
+
+// JWSHeader contains the header parameters of a JWS needed to verify it
+type JWSHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// JWS is a JSON Web Signature in compact serialization, with its header and claims decoded
+type JWS struct {
+	Header    JWSHeader
+	Claims    map[string]interface{}
+	Signature []byte
+	Raw       string
+}
+
+// ParseJWS decodes a compact JWS without verifying its signature
+func ParseJWS(token string) (*JWS, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidFormat
+	}
+	jwt := &JWS{Raw: strings.Join(parts, ".")}
+	header, err := decodeSegment(parts[0])
+	if err != nil || json.Unmarshal(header, &jwt.Header) != nil {
+		return nil, ErrInvalidFormat
+	}
+	payload, err := decodeSegment(parts[1])
+	if err != nil || json.Unmarshal(payload, &jwt.Claims) != nil {
+		return nil, ErrInvalidFormat
+	}
+	jwt.Signature, err = decodeSegment(parts[2])
+	if err != nil {
+		return nil, ErrInvalidFormat
+	}
+	return jwt, nil
+}
+
+// SigningInput returns the part of the token covered by the signature
+func (j *JWS) SigningInput() string {
+	return j.Raw[:strings.LastIndex(j.Raw, ".")]
+}
+
+// StringClaim returns the value of a string claim, or the first value if the claim is an array like aud
+func (j *JWS) StringClaim(name string) string {
+	switch v := j.Claims[name].(type) {
+	case string:
+		return v
+	case []interface{}:
+		if len(v) > 0 {
+			if s, ok := v[0].(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// HasAudience tells whether the aud claim, as a single string or an array of them, names the audience
+func (j *JWS) HasAudience(audience string) bool {
+	switch v := j.Claims["aud"].(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, aud := range v {
+			if aud == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DateClaim returns the value of a NumericDate claim like nbf or exp
+func (j *JWS) DateClaim(name string) *TimeWithFormat {
+	seconds, ok := j.Claims[name].(float64)
+	if !ok {
+		return nil
+	}
+	return &TimeWithFormat{Time: time.Unix(int64(seconds), 0).UTC()}
+}
+
+// ParseJWTCredential decodes a JWT encoded credential following the VC Data Model JWT encoding: the vc claim holds the
+// credential and the registered claims take precedence over their equivalent properties.
+// The token is kept as the proof of the credential, so it can be verified afterwards.
+func ParseJWTCredential(token string) (*VerifiableCredential, error) {
+	jwt, err := ParseJWS(token)
+	if err != nil {
+		return nil, err
+	}
+	claim, ok := jwt.Claims["vc"].(map[string]interface{})
+	if !ok {
+		return nil, ErrInvalidFormat
+	}
+	vc := &VerifiableCredential{}
+	if err = decodeClaim(claim, vc); err != nil {
+		return nil, ErrInvalidFormat
+	}
+	if iss := jwt.StringClaim("iss"); iss != "" {
+		vc.Issuer = iss
+	}
+	if jti := jwt.StringClaim("jti"); jti != "" {
+		vc.Id = jti
+	}
+	if sub := jwt.StringClaim("sub"); sub != "" {
+		if vc.CredentialSubject == nil {
+			vc.CredentialSubject = &map[string]interface{}{}
+		}
+		(*vc.CredentialSubject)["id"] = sub
+	}
+	if nbf := jwt.DateClaim("nbf"); nbf != nil {
+		vc.IssuanceDate = nbf
+	}
+	if exp := jwt.DateClaim("exp"); exp != nil {
+		vc.ExpirationDate = exp
+	}
+	vc.Proof = jwt.proof(vc.Issuer, "assertionMethod")
+	return vc, nil
+}
+
+// ParseJWTPresentation decodes a JWT encoded presentation, whose vp claim holds the presentation and the iss claim its holder.
+// The token is kept as the proof of the presentation, so it can be verified afterwards.
+func ParseJWTPresentation(token string) (*VerifiablePresentation, error) {
+	jwt, err := ParseJWS(token)
+	if err != nil {
+		return nil, err
+	}
+	claim, ok := jwt.Claims["vp"].(map[string]interface{})
+	if !ok {
+		return nil, ErrInvalidFormat
+	}
+	vp := &VerifiablePresentation{}
+	if err = decodeClaim(claim, vp); err != nil {
+		return nil, ErrInvalidFormat
+	}
+	if iss := jwt.StringClaim("iss"); iss != "" {
+		vp.Holder = &iss
+	}
+	if nbf := jwt.DateClaim("nbf"); nbf != nil {
+		vp.IssuanceDate = nbf
+	}
+	if exp := jwt.DateClaim("exp"); exp != nil {
+		vp.ExpirationDate = exp
+	}
+	holder := ""
+	if vp.Holder != nil {
+		holder = *vp.Holder
+	}
+	vp.Proof = jwt.proof(holder, "authentication")
+	return vp, nil
+}
+
+// proof represents the signature of the token as a proof of the given signer.
+// Relative key ids are resolved against the DID of the signer.
+func (j *JWS) proof(signer, purpose string) *SSIProof {
+	kid := j.Header.Kid
+	if kid == "" || strings.HasPrefix(kid, "#") {
+		kid = signer + kid
+	}
+	created := j.DateClaim("iat")
+	if created == nil {
+		created = j.DateClaim("nbf")
+	}
+	return &SSIProof{Value: &Proof{
+		Challenge:          j.StringClaim("nonce"),
+		Created:            created,
+		Domain:             j.StringClaim("aud"),
+		Jws:                j.Raw,
+		ProofPurpose:       purpose,
+		Type:               ProofTypeJWT,
+		VerificationMethod: kid,
+	}}
+}
+
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+}
+
+func decodeClaim(claim map[string]interface{}, target interface{}) error {
+	data, err := json.Marshal(claim)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
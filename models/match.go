@@ -0,0 +1,45 @@
+package models
+
+// MatchResult tells which of the credentials of a holder satisfy a presentation definition,
+// and proposes the submission to present them
+type MatchResult struct {
+	Satisfied            bool                    `json:"satisfied" description:"Whether the candidate credentials satisfy the whole definition"`
+	Descriptors          []DescriptorMatch       `json:"descriptors" description:"Candidate credentials satisfying each input descriptor"`
+	Requirements         []RequirementMatch      `json:"requirements,omitempty" description:"Outcome of each submission requirement of the definition"`
+	Errors               []string                `json:"errors,omitempty" description:"Reasons why the definition cannot be satisfied"`
+	Submission           *PresentationSubmission `json:"presentation_submission,omitempty" description:"Submission of the selected credentials, when the definition is satisfied"`
+	VerifiableCredential []VerifiableCredential  `json:"verifiableCredential,omitempty" description:"Selected credentials, in the order referenced by the submission"`
+}
+
+// DescriptorMatch lists the candidate credentials satisfying an input descriptor
+type DescriptorMatch struct {
+	ID          string             `json:"id" description:"Id of the input descriptor" example:"banking_input"`
+	Credentials []int              `json:"credentials" description:"Positions of the candidates satisfying the descriptor"`
+	Reports     []DescriptorReport `json:"reports,omitempty" description:"Checks performed on every candidate against the descriptor"`
+}
+
+// RequirementMatch is the outcome of a submission requirement over the candidate credentials
+type RequirementMatch struct {
+	Name        string   `json:"name,omitempty" description:"Name of the submission requirement"`
+	Satisfied   bool     `json:"satisfied" description:"Whether enough descriptors can be satisfied"`
+	Descriptors []string `json:"descriptors,omitempty" description:"Input descriptors selected to satisfy the requirement"`
+	Errors      []string `json:"errors,omitempty" description:"Reasons why the requirement cannot be satisfied"`
+}
+
+// IsMatched tells if any candidate satisfies the descriptor
+func (d *DescriptorMatch) IsMatched() bool {
+	return len(d.Credentials) > 0
+}
+
+// Presentation returns the unsigned presentation of the selected credentials with their submission,
+// ready to be proved by the holder. It is nil when the definition isn't satisfied.
+func (m *MatchResult) Presentation() *VerifiablePresentation {
+	if m.Submission == nil {
+		return nil
+	}
+	return &VerifiablePresentation{
+		Type:                   []string{"VerifiablePresentation"},
+		PresentationSubmission: m.Submission,
+		VerifiableCredential:   m.VerifiableCredential,
+	}
+}
@@ -0,0 +1,54 @@
+package models
+
+// Mechanism is the way an input descriptor is satisfied by the proofs of the presentation, instead of by a credential
+type Mechanism string
+
+const (
+	// MechanismAuthnFactor is satisfied by proofs of second factors of authentication, like an email or a phone key
+	MechanismAuthnFactor Mechanism = "authn_factor"
+	// MechanismWalletAuth is satisfied by the proof of a trusted wallet application
+	MechanismWalletAuth Mechanism = "wallet_auth"
+)
+
+// IdentityGroup is the group of the descriptors of identity verification added before descriptors stated their mechanism
+const IdentityGroup = "identity"
+
+const walletAuthDescriptor = "wallet_auth"
+
+// IsValid tells if the mechanism is one of the mechanisms supported
+func (m Mechanism) IsValid() bool {
+	return m == MechanismAuthnFactor || m == MechanismWalletAuth
+}
+
+// GetMechanism returns the mechanism satisfying the descriptor, if it isn't satisfied by a credential.
+// Definitions stored before descriptors stated their mechanism are recognised by the identity group.
+func (i *InputDescriptor) GetMechanism() Mechanism {
+	if i.Mechanism != "" {
+		return i.Mechanism
+	}
+	for _, group := range i.Group {
+		if group != IdentityGroup {
+			continue
+		}
+		if i.ID == walletAuthDescriptor {
+			return MechanismWalletAuth
+		}
+		return MechanismAuthnFactor
+	}
+	return ""
+}
+
+// ProofPatterns returns the patterns of the fields of a mechanism descriptor, each one matching the keys of the
+// proofs accepted for the field. Every field must be proved, so a descriptor can require several factors.
+func (i *InputDescriptor) ProofPatterns() []string {
+	patterns := []string{}
+	if i.Constraints == nil {
+		return patterns
+	}
+	for _, field := range i.Constraints.Fields {
+		if field.Filter != nil && field.Filter.Pattern != "" {
+			patterns = append(patterns, field.Filter.Pattern)
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInputDescriptor_GetMechanism(t *testing.T) {
+	tests := []struct {
+		name       string
+		descriptor InputDescriptor
+		mechanism  Mechanism
+	}{
+		{"Credential descriptor", InputDescriptor{ID: "email", Group: []string{"A"}}, ""},
+		{"Stated mechanism", InputDescriptor{ID: "factores", Group: []string{"verificacion"}, Mechanism: MechanismAuthnFactor}, MechanismAuthnFactor},
+		{"Legacy second factors", InputDescriptor{ID: "authnfactors", Group: []string{IdentityGroup}}, MechanismAuthnFactor},
+		{"Legacy wallet authentication", InputDescriptor{ID: "wallet_auth", Group: []string{IdentityGroup}}, MechanismWalletAuth},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.mechanism, test.descriptor.GetMechanism())
+		})
+	}
+
+	descriptor := NewInputDescriptor("factors", "Factors", "", "")
+	assert.Equal(t, ErrBadParamInput, descriptor.SetMechanism("sms"))
+	assert.NoError(t, descriptor.SetMechanism(MechanismWalletAuth))
+	assert.Empty(t, descriptor.ProofPatterns())
+	descriptor.Constraints = &Constraints{Fields: []Field{
+		{Path: []string{"vp.proof.creator"}, Filter: &Filter{Type: "string", Pattern: "#email$"}},
+		{Path: []string{"vp.proof.creator"}},
+		{Path: []string{"vp.proof.creator"}, Filter: &Filter{Type: "string", Pattern: "#phone$"}},
+	}}
+	assert.Equal(t, []string{"#email$", "#phone$"}, descriptor.ProofPatterns())
+}
+
+func TestLint_Mechanism(t *testing.T) {
+	pd := &PresentationDefinition{}
+	pd.ID = "factors"
+	pd.InputDescriptors = []InputDescriptor{
+		{ID: "authnfactors", Group: []string{IdentityGroup}},
+		{ID: "otp", Mechanism: "otp", Constraints: &Constraints{Fields: []Field{{Path: []string{"vp.proof.creator"}, Filter: &Filter{Pattern: "#otp$"}}}}},
+	}
+	issues, err := Lint(pd)
+	assert.Equal(t, ErrInvalidDefinition, err)
+	paths := []string{}
+	for _, issue := range issues {
+		paths = append(paths, issue.Path)
+	}
+	assert.Equal(t, []string{"$.input_descriptors[0].constraints.fields", "$.input_descriptors[1].mechanism"}, paths)
+}
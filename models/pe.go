@@ -11,6 +11,10 @@ type PExchange struct {
 	PresentationSubmission *VerifiablePresentation
 	Validations            *VerificationResult
 	PresentationDefinition *PresentationDefinition
+	Policy                 *VerificationPolicy
+	Challenge              string
+	Domain                 string
+	ChallengeUsedAt        *time.Time
 	RequestedAt            *time.Time
 	CreatedAt              *time.Time
 	UpdatedAt              *time.Time
@@ -178,11 +178,77 @@ func (i *InputDescriptor) SetSubjectIsHolder(preference Preference) error {
 
 func (i *InputDescriptor) SetConstraintsLimitDisclosure(limitDisclosure bool) {
 	if i.Constraints == nil {
-		i.Constraints = &Constraints{
-			LimitDisclosure: limitDisclosure,
-		}
+		i.Constraints = &Constraints{}
+	}
+	i.Constraints.LimitDisclosure = NewLegacyDisclosure(limitDisclosure)
+}
+
+// SetLimitDisclosure sets the limit_disclosure constraint as a preference, following PE v2
+func (i *InputDescriptor) SetLimitDisclosure(preference Preference) {
+	if i.Constraints == nil {
+		i.Constraints = &Constraints{}
+	}
+	i.Constraints.LimitDisclosure = &Disclosure{Preference: preference}
+}
+
+// SetFormat restricts the formats accepted for this descriptor, overriding the format of the definition
+func (i *InputDescriptor) SetFormat(format Format) {
+	i.Format = &format
+}
+
+// SetLevelOfAssurance requires the evidence of the credential to reach an eIDAS level of assurance
+func (i *InputDescriptor) SetLevelOfAssurance(level LevelOfAssurance) error {
+	if !level.IsValid() {
+		return ErrBadParamInput
+	}
+	i.LevelOfAssurance = level
+	return nil
+}
+
+// SetTrustLevel requires the issuer of the credential to be accredited with a minimum level of trust, which is
+// checked against the governance framework when the credential is submitted
+func (i *InputDescriptor) SetTrustLevel(level int) error {
+	if level < 0 {
+		return ErrBadParamInput
+	}
+	i.TrustLevel = level
+	return nil
+}
+
+// SetMechanism makes the descriptor be satisfied by the proofs of the presentation matching the patterns of its fields,
+// instead of by a credential
+func (i *InputDescriptor) SetMechanism(mechanism Mechanism) error {
+	if !mechanism.IsValid() {
+		return ErrBadParamInput
+	}
+	i.Mechanism = mechanism
+	return nil
+}
+
+// AddIsHolder asks for the holder to be the subject of the fields with the given ids, which must belong to the descriptor
+func (i *InputDescriptor) AddIsHolder(directive Preference, fieldIds ...string) error {
+	if i.Constraints == nil || !i.Constraints.HasField(fieldIds) {
+		return fmt.Errorf("is_holder must refer to fields of the descriptor: %v", fieldIds)
+	}
+	sd := SubjectDirective{FieldId: fieldIds, Directive: directive}
+	if err := Validate(sd); err != nil {
+		return err
 	}
-	i.Constraints.LimitDisclosure = limitDisclosure
+	i.Constraints.IsHolder = append(i.Constraints.IsHolder, sd)
+	return nil
+}
+
+// AddSameSubject asks for the fields with the given ids, of this or other descriptors, to be about the same subject
+func (i *InputDescriptor) AddSameSubject(directive Preference, fieldIds ...string) error {
+	sd := SubjectDirective{FieldId: fieldIds, Directive: directive}
+	if err := Validate(sd); err != nil {
+		return err
+	}
+	if i.Constraints == nil {
+		i.Constraints = &Constraints{}
+	}
+	i.Constraints.SameSubject = append(i.Constraints.SameSubject, sd)
+	return nil
 }
 
 func NewConstraintsField(path []string) *Field {
@@ -193,6 +259,21 @@ func (f *Field) SetPurpose(purpose string) {
 	f.Purpose = purpose
 }
 
+// SetID identifies the field, so is_holder and same_subject directives can refer to it
+func (f *Field) SetID(id string) {
+	f.Id = id
+}
+
+// SetOptional lets the holder omit the field without failing the descriptor
+func (f *Field) SetOptional(optional bool) {
+	f.Optional = optional
+}
+
+// SetIntentToRetain informs the holder that the verifier intends to retain the value of the field
+func (f *Field) SetIntentToRetain(intentToRetain bool) {
+	f.IntentToRetain = intentToRetain
+}
+
 func (f *Field) SetFilter(filter Filter) error {
 	if err := Validate(filter); err != nil {
 		return err
@@ -201,6 +282,16 @@ func (f *Field) SetFilter(filter Filter) error {
 	return nil
 }
 
+// SetPredicate asks the holder for the boolean result of the filter of the field, instead of its value.
+// The filter must be set beforehand, as it is the condition evaluated by the holder.
+func (f *Field) SetPredicate(preference Preference) error {
+	if f.Filter == nil {
+		return fmt.Errorf("field cannot have a predicate preference without a filter: %+v", f)
+	}
+	f.Predicate = &preference
+	return nil
+}
+
 type PresentationSubmissionBuilder struct {
 	Submission PresentationSubmission
 }
@@ -229,6 +320,19 @@ func (p *PresentationSubmissionBuilder) AddDescriptor(d Descriptor) error {
 	return nil
 }
 
+// SetPathNested points the descriptor into a credential nested in the document found at its path.
+// The nested descriptor must refer to the same input descriptor.
+func (d *Descriptor) SetPathNested(nested Descriptor) error {
+	if nested.ID != d.ID {
+		return fmt.Errorf("nested descriptor %s doesn't refer to input descriptor %s", nested.ID, d.ID)
+	}
+	if err := Validate(nested); err != nil {
+		return err
+	}
+	d.PathNested = &nested
+	return nil
+}
+
 func (p *PresentationSubmissionBuilder) SetID(id string) {
 	p.Submission.ID = id
 }
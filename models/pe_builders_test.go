@@ -128,6 +128,13 @@ func TestPresentationSubmissionBuilder(t *testing.T) {
 	})
 	assert.NoError(t, err)
 
+	// Credential nested in a JWT presentation
+	nested := Descriptor{ID: "passport_input", Format: CredentialFormat(JWTVP), Path: "$"}
+	assert.Error(t, nested.SetPathNested(Descriptor{ID: "other_input", Format: CredentialFormat(JWTVC), Path: "$.vp.verifiableCredential[0]"}))
+	assert.NoError(t, nested.SetPathNested(Descriptor{ID: "passport_input", Format: CredentialFormat(JWTVC), Path: "$.vp.verifiableCredential[0]"}))
+	assert.Equal(t, []string{"$", "$.vp.verifiableCredential[0]"}, nested.FullPath())
+	assert.NoError(t, b.AddDescriptor(nested))
+
 	presSub, err := b.Build()
 	assert.NoError(t, err)
 	assert.NoError(t, Validate(presSub))
@@ -144,3 +151,22 @@ func TestPresentationSubmissionBuilder(t *testing.T) {
 	// assert.NoError(t, err)
 	// assert.True(t, same)
 }
+
+func TestField_SetPredicate(t *testing.T) {
+	field := NewConstraintsField([]string{"$.credentialSubject.age"})
+	err := field.SetPredicate(Required)
+	assert.Error(t, err)
+	assert.Nil(t, field.Predicate)
+
+	err = field.SetFilter(Filter{
+		Type:    "integer",
+		Minimum: 18,
+	})
+	assert.NoError(t, err)
+	err = field.SetPredicate(Required)
+	assert.NoError(t, err)
+	assert.Equal(t, Required, *field.Predicate)
+
+	id := NewInputDescriptor("age_input", "Age over 18", "", "")
+	assert.NoError(t, id.SetConstraints(*field))
+}
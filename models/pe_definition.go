@@ -32,6 +32,12 @@ const (
 	Preferred Preference = "preferred"
 )
 
+// Versions of the DIF Presentation Exchange specification understood by the definitions
+const (
+	PEVersion1 = "1.0.0"
+	PEVersion2 = "2.0.0"
+)
+
 type PresentationDefinitionHolder struct {
 	PresentationDefinition `json:"presentation_definition" validate:"required"`
 }
@@ -39,7 +45,9 @@ type PresentationDefinitionHolder struct {
 type PresentationDefinition struct {
 	DataAgreement *DataAgreementRef `json:"dataAgreement,omitempty"`
 	DIFPresentationDefinition
-	Proof *SSIProof `json:"proof,omitempty"`
+	Challenge string    `json:"challenge,omitempty" description:"Nonce of the exchange that the proof of the presentation must include"`
+	Domain    string    `json:"domain,omitempty" description:"Domain of the verifier that the proof of the presentation must include"`
+	Proof     *SSIProof `json:"proof,omitempty"`
 }
 
 type DataAgreementRef struct {
@@ -96,6 +104,29 @@ type DIFPresentationDefinition struct {
 	InputDescriptors       []InputDescriptor       `json:"input_descriptors" validate:"required"`
 }
 
+// Version detects the version of the Presentation Exchange specification followed by the definition,
+// looking for the features introduced by PE v2. Definitions without them are handled as the early drafts.
+func (d *DIFPresentationDefinition) Version() string {
+	for _, descriptor := range d.InputDescriptors {
+		if len(descriptor.Schema) == 0 || descriptor.Format != nil {
+			return PEVersion2
+		}
+		c := descriptor.Constraints
+		if c == nil {
+			continue
+		}
+		if len(c.IsHolder) > 0 || len(c.SameSubject) > 0 || (c.LimitDisclosure != nil && !c.LimitDisclosure.legacy) {
+			return PEVersion2
+		}
+		for _, field := range c.Fields {
+			if field.Id != "" || field.Optional || field.IntentToRetain {
+				return PEVersion2
+			}
+		}
+	}
+	return PEVersion1
+}
+
 func (p *PresentationDefinition) IsRequest() bool {
 	return true
 }
@@ -122,6 +153,57 @@ type LDPType struct {
 	ProofType []string `json:"proof_type,omitempty" validate:"required"`
 }
 
+// AllowsCredentialProof tells if the format accepts a credential proof of the given type, or of the given algorithm
+// for credentials received as JWT. Formats without any credential entry accept every proof.
+func (f *Format) AllowsCredentialProof(proofType, alg string) bool {
+	if f == nil {
+		return true
+	}
+	return allowsProof([]*JWTType{f.JWT, f.JWTVC}, []*LDPType{f.LDP, f.LDPVC}, proofType, alg)
+}
+
+// AllowsPresentationProof tells if the format accepts a presentation proof of the given type, or of the given algorithm
+// for presentations received as JWT. Formats without any presentation entry accept every proof.
+func (f *Format) AllowsPresentationProof(proofType, alg string) bool {
+	if f == nil {
+		return true
+	}
+	return allowsProof([]*JWTType{f.JWT, f.JWTVP}, []*LDPType{f.LDP, f.LDPVP}, proofType, alg)
+}
+
+func allowsProof(jwtTypes []*JWTType, ldpTypes []*LDPType, proofType, alg string) bool {
+	declared := false
+	accepted, value := []string{}, proofType
+	if proofType == ProofTypeJWT {
+		value = alg
+	}
+	for _, jwtType := range jwtTypes {
+		if jwtType != nil {
+			declared = true
+			if proofType == ProofTypeJWT {
+				accepted = append(accepted, jwtType.Alg...)
+			}
+		}
+	}
+	for _, ldpType := range ldpTypes {
+		if ldpType != nil {
+			declared = true
+			if proofType != ProofTypeJWT {
+				accepted = append(accepted, ldpType.ProofType...)
+			}
+		}
+	}
+	if !declared {
+		return true
+	}
+	for _, a := range accepted {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
+
 type SubmissionRequirement struct {
 	Name    string    `json:"name,omitempty"`
 	Purpose string    `json:"purpose,omitempty"`
@@ -140,13 +222,17 @@ type FromOption struct {
 }
 
 type InputDescriptor struct {
-	ID          string       `json:"id,omitempty" validate:"required"`
-	Name        string       `json:"name,omitempty"`
-	Purpose     string       `json:"purpose,omitempty"`
-	Metadata    string       `json:"metadata,omitempty"`
-	Group       []string     `json:"group,omitempty"`
-	Schema      []Schema     `json:"schema,omitempty" validate:"required,min=1"`
-	Constraints *Constraints `json:"constraints,omitempty"`
+	ID               string           `json:"id,omitempty" validate:"required"`
+	Name             string           `json:"name,omitempty"`
+	Purpose          string           `json:"purpose,omitempty"`
+	Metadata         string           `json:"metadata,omitempty"`
+	Group            []string         `json:"group,omitempty"`
+	Format           *Format          `json:"format,omitempty"`
+	Schema           []Schema         `json:"schema,omitempty" validate:"omitempty,min=1"` //Required until PE v2
+	Constraints      *Constraints     `json:"constraints,omitempty"`
+	LevelOfAssurance LevelOfAssurance `json:"level_of_assurance,omitempty" validate:"omitempty,oneof=low substantial high"` //Derived from the credential evidence
+	TrustLevel       int              `json:"trust_level,omitempty" validate:"omitempty,min=0"`                             //Minimum level of trust of the issuer accreditation
+	Mechanism        Mechanism        `json:"mechanism,omitempty" validate:"omitempty,oneof=authn_factor wallet_auth"`      //Satisfied by the proofs of the presentation
 }
 
 type Schema struct {
@@ -155,17 +241,99 @@ type Schema struct {
 }
 
 type Constraints struct {
-	LimitDisclosure bool        `json:"limit_disclosure,omitempty"`
-	Fields          []Field     `json:"fields,omitempty"`
-	SubjectIsIssuer *Preference `json:"subject_is_issuer,omitempty"`
-	SubjectIsHolder *Preference `json:"subject_is_holder,omitempty"`
+	LimitDisclosure *Disclosure        `json:"limit_disclosure,omitempty"`
+	Fields          []Field            `json:"fields,omitempty"`
+	SubjectIsIssuer *Preference        `json:"subject_is_issuer,omitempty"`
+	SubjectIsHolder *Preference        `json:"subject_is_holder,omitempty"`
+	IsHolder        []SubjectDirective `json:"is_holder,omitempty" validate:"dive"`
+	SameSubject     []SubjectDirective `json:"same_subject,omitempty" validate:"dive"`
+}
+
+// SubjectDirective asks for the subject of the fields with the given ids to be the holder (is_holder), or the same
+// one across credentials (same_subject)
+type SubjectDirective struct {
+	FieldId   []string   `json:"field_id" validate:"required,min=1"`
+	Directive Preference `json:"directive" validate:"required"`
+}
+
+// Disclosure is the limit_disclosure constraint, a boolean on the early drafts of PE and a preference since PE v2.
+// It keeps the form it was received with, so definitions round trip unchanged.
+type Disclosure struct {
+	Preference Preference
+	legacy     bool
+}
+
+// NewLegacyDisclosure returns the limit_disclosure constraint in its boolean form
+func NewLegacyDisclosure(limit bool) *Disclosure {
+	d := &Disclosure{legacy: true}
+	if limit {
+		d.Preference = Required
+	}
+	return d
+}
+
+func (d *Disclosure) UnmarshalJSON(jsonData []byte) error {
+	var limit bool
+	if err := json.Unmarshal(jsonData, &limit); err == nil {
+		*d = *NewLegacyDisclosure(limit)
+		return nil
+	}
+	var preference Preference
+	if err := json.Unmarshal(jsonData, &preference); err != nil {
+		return errors.New("unrecognized limit disclosure value")
+	}
+	d.Preference = preference
+	d.legacy = false
+	return nil
+}
+
+func (d Disclosure) MarshalJSON() ([]byte, error) {
+	if d.legacy {
+		return json.Marshal(d.Preference == Required)
+	}
+	return json.Marshal(d.Preference)
+}
+
+// IsLimited tells if the constraint asks to limit the disclosure, either as a requirement or as a preference
+func (d *Disclosure) IsLimited() bool {
+	return d != nil && (d.Preference == Required || d.Preference == Preferred)
+}
+
+// HolderDirective returns the strongest preference binding the subject of the credential to the holder,
+// from either subject_is_holder or the is_holder directives. Nil means no binding is requested.
+func (c *Constraints) HolderDirective() *Preference {
+	var directive *Preference
+	if c.SubjectIsHolder != nil {
+		directive = c.SubjectIsHolder
+	}
+	for i := range c.IsHolder {
+		if directive == nil || c.IsHolder[i].Directive == Required {
+			directive = &c.IsHolder[i].Directive
+		}
+	}
+	return directive
+}
+
+// HasField tells if any field of the constraints has one of the given ids
+func (c *Constraints) HasField(ids []string) bool {
+	for _, field := range c.Fields {
+		for _, id := range ids {
+			if field.Id != "" && field.Id == id {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 type Field struct {
-	Path      []string    `json:"path,omitempty" validate:"required"`
-	Purpose   string      `json:"purpose,omitempty"`
-	Filter    *Filter     `json:"filter,omitempty"`
-	Predicate *Preference `json:"predicate,omitempty"`
+	Id             string      `json:"id,omitempty"`
+	Path           []string    `json:"path,omitempty" validate:"required"`
+	Purpose        string      `json:"purpose,omitempty"`
+	Filter         *Filter     `json:"filter,omitempty"`
+	Predicate      *Preference `json:"predicate,omitempty"`
+	Optional       bool        `json:"optional,omitempty"`
+	IntentToRetain bool        `json:"intent_to_retain,omitempty"`
 }
 
 type Filter struct {
@@ -181,4 +349,5 @@ type Filter struct {
 	Const            StringOrInteger   `json:"const,omitempty"`
 	Enum             []StringOrInteger `json:"enum,omitempty"`
 	Not              *Filter           `json:"not,omitempty"`
+	Contains         *Filter           `json:"contains,omitempty"`
 }
@@ -61,6 +61,42 @@ func TestPresentationDefinition(t *testing.T) {
 		assert.NoError(t, err)
 		assert.True(t, true)
 	})
+
+	t.Run("V2", func(t *testing.T) {
+		var presDef PresentationDefinitionHolder
+		presDefBytes := []byte(testdata.V2PresentationDefinition)
+		err := json.Unmarshal(presDefBytes, &presDef)
+		assert.NoError(t, err)
+
+		assert.NoError(t, Validate(presDef))
+		assert.Equal(t, PEVersion2, presDef.Version())
+		constraints := presDef.InputDescriptors[0].Constraints
+		assert.True(t, constraints.LimitDisclosure.IsLimited())
+		assert.Equal(t, Required, *constraints.HolderDirective())
+		assert.True(t, constraints.Fields[1].Optional)
+
+		// Roundtrip and compare
+		roundTripBytes, err := json.Marshal(presDef)
+		assert.NoError(t, err)
+
+		equal, err := JSONBytesEqual(presDefBytes, roundTripBytes)
+		assert.NoError(t, err)
+		assert.True(t, equal)
+	})
+}
+
+func TestPresentationDefinition_Version(t *testing.T) {
+	var presDef PresentationDefinitionHolder
+	assert.NoError(t, json.Unmarshal([]byte(testdata.BasicPresentationDefinition), &presDef))
+	assert.Equal(t, PEVersion1, presDef.Version())
+
+	presDef.InputDescriptors[0].Constraints.Fields[0].SetID("issuer")
+	assert.Equal(t, PEVersion2, presDef.Version())
+
+	invalid := SubjectDirective{Directive: Required}
+	assert.Error(t, Validate(invalid))
+	assert.Error(t, presDef.InputDescriptors[1].AddIsHolder(Required, "issuer"))
+	assert.NoError(t, presDef.InputDescriptors[0].AddIsHolder(Required, "issuer"))
 }
 
 func TestPresentationDefinitionBuilder(t *testing.T) {
@@ -164,3 +200,25 @@ func TestPresentationDefinitionBuilder(t *testing.T) {
 		assert.NotNil(t, pres)
 	})
 }
+
+func TestFormat_AllowsProof(t *testing.T) {
+	format := &Format{
+		JWTVC: &JWTType{Alg: []string{"ES256", "EdDSA"}},
+		LDPVC: &LDPType{ProofType: []string{"Ed25519Signature2018"}},
+		LDPVP: &LDPType{ProofType: []string{"JsonWebSignature2020"}},
+	}
+
+	assert.True(t, format.AllowsCredentialProof("Ed25519Signature2018", ""))
+	assert.False(t, format.AllowsCredentialProof("RsaSignature2018", ""))
+	assert.True(t, format.AllowsCredentialProof(ProofTypeJWT, "ES256"))
+	assert.False(t, format.AllowsCredentialProof(ProofTypeJWT, "HS256"))
+	assert.False(t, format.AllowsCredentialProof("", ""))
+
+	assert.True(t, format.AllowsPresentationProof("JsonWebSignature2020", ""))
+	assert.False(t, format.AllowsPresentationProof("Ed25519Signature2018", ""))
+	assert.False(t, format.AllowsPresentationProof(ProofTypeJWT, "ES256"))
+
+	var unrestricted *Format
+	assert.True(t, unrestricted.AllowsCredentialProof("RsaSignature2018", ""))
+	assert.True(t, (&Format{LDPVC: &LDPType{ProofType: []string{"Ed25519Signature2018"}}}).AllowsPresentationProof("RsaSignature2018", ""))
+}
@@ -0,0 +1,221 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/ohler55/ojg/jp"
+)
+
+// LintIssue is an authoring error of a presentation definition, located by the path of the element causing it
+type LintIssue struct {
+	Path    string `json:"path" example:"$.input_descriptors[0].constraints.fields[1].filter.pattern" description:"Element of the definition causing the issue"`
+	Message string `json:"message" example:"pattern doesn't compile" description:"Description of the issue"`
+}
+
+func (i LintIssue) String() string {
+	return i.Path + ": " + i.Message
+}
+
+// Lint looks for authoring errors of a presentation definition that would make valid submissions fail,
+// like requirements without descriptors or filters that can't be evaluated. It returns ErrInvalidDefinition
+// along with the issues found.
+func Lint(pd *PresentationDefinition) ([]LintIssue, error) {
+	if pd == nil {
+		return nil, ErrBadParamInput
+	}
+	issues := []LintIssue{}
+	groups := map[string]bool{}
+	ids := map[string]bool{}
+	for i, descriptor := range pd.InputDescriptors {
+		path := fmt.Sprintf("$.input_descriptors[%d]", i)
+		if ids[descriptor.ID] {
+			issues = append(issues, LintIssue{path + ".id", fmt.Sprintf("descriptor id %s is duplicated", descriptor.ID)})
+		}
+		ids[descriptor.ID] = true
+		for _, group := range descriptor.Group {
+			groups[group] = true
+		}
+		if len(pd.SubmissionRequirements) > 0 && len(descriptor.Group) == 0 {
+			issues = append(issues, LintIssue{path + ".group", fmt.Sprintf("descriptor %s is in no group, so no submission requirement can select it", descriptor.ID)})
+		}
+		issues = append(issues, lintConstraints(path+".constraints", descriptor.Constraints)...)
+		if descriptor.LevelOfAssurance != "" && !descriptor.LevelOfAssurance.IsValid() {
+			issues = append(issues, LintIssue{path + ".level_of_assurance", fmt.Sprintf("level of assurance %s is not low, substantial or high", descriptor.LevelOfAssurance)})
+		}
+		if descriptor.Mechanism != "" && !descriptor.Mechanism.IsValid() {
+			issues = append(issues, LintIssue{path + ".mechanism", fmt.Sprintf("mechanism %s is not authn_factor or wallet_auth", descriptor.Mechanism)})
+		} else if descriptor.GetMechanism() != "" && len(descriptor.ProofPatterns()) == 0 {
+			issues = append(issues, LintIssue{path + ".constraints.fields", fmt.Sprintf("descriptor %s has no field with a pattern of the proofs satisfying it", descriptor.ID)})
+		}
+	}
+	for i, descriptor := range pd.InputDescriptors {
+		issues = append(issues, lintSameSubject(fmt.Sprintf("$.input_descriptors[%d].constraints.same_subject", i), descriptor.Constraints, pd.InputDescriptors)...)
+	}
+	for i, req := range pd.SubmissionRequirements {
+		issues = append(issues, lintRequirement(fmt.Sprintf("$.submission_requirements[%d]", i), &req, groups)...)
+	}
+	if len(issues) > 0 {
+		return issues, ErrInvalidDefinition
+	}
+	return issues, nil
+}
+
+// Lint checks the advanced definition of the tenant, if any, before the configuration is stored
+func (t *TenantConfig) Lint() ([]LintIssue, error) {
+	if t.AdvancedDefinition == nil {
+		return []LintIssue{}, nil
+	}
+	return Lint(t.AdvancedDefinition)
+}
+
+func lintRequirement(path string, req *SubmissionRequirement, groups map[string]bool) []LintIssue {
+	issues := []LintIssue{}
+	if req.From != "" && !groups[req.From] {
+		issues = append(issues, LintIssue{path + ".from", fmt.Sprintf("group %s has no input descriptors", req.From)})
+	}
+	for i, nested := range req.FromNested {
+		issues = append(issues, lintRequirement(fmt.Sprintf("%s.from_nested[%d]", path, i), &nested, groups)...)
+	}
+	return issues
+}
+
+func lintConstraints(path string, constraints *Constraints) []LintIssue {
+	issues := []LintIssue{}
+	if constraints == nil {
+		return issues
+	}
+	for i, field := range constraints.Fields {
+		fieldPath := fmt.Sprintf("%s.fields[%d]", path, i)
+		for j, p := range field.Path {
+			if _, err := jp.ParseString(p); err != nil {
+				issues = append(issues, LintIssue{fmt.Sprintf("%s.path[%d]", fieldPath, j), fmt.Sprintf("invalid JSONPath %s: %s", p, err.Error())})
+			}
+		}
+		issues = append(issues, lintFilter(fieldPath+".filter", field.Filter)...)
+	}
+	for i, directive := range constraints.IsHolder {
+		if !constraints.HasField(directive.FieldId) {
+			issues = append(issues, LintIssue{fmt.Sprintf("%s.is_holder[%d].field_id", path, i), fmt.Sprintf("fields %v are not in the descriptor", directive.FieldId)})
+		}
+	}
+	return issues
+}
+
+func lintSameSubject(path string, constraints *Constraints, descriptors []InputDescriptor) []LintIssue {
+	issues := []LintIssue{}
+	if constraints == nil {
+		return issues
+	}
+	for i, directive := range constraints.SameSubject {
+		for _, id := range directive.FieldId {
+			found := false
+			for _, descriptor := range descriptors {
+				if descriptor.Constraints != nil && descriptor.Constraints.HasField([]string{id}) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				issues = append(issues, LintIssue{fmt.Sprintf("%s[%d].field_id", path, i), fmt.Sprintf("field %s is not in any descriptor", id)})
+			}
+		}
+	}
+	return issues
+}
+
+func lintFilter(path string, filter *Filter) []LintIssue {
+	issues := []LintIssue{}
+	if filter == nil {
+		return issues
+	}
+	if filter.Pattern != "" {
+		if _, err := regexp.Compile(filter.Pattern); err != nil {
+			issues = append(issues, LintIssue{path + ".pattern", fmt.Sprintf("pattern doesn't compile: %s", err.Error())})
+		}
+	}
+	if filter.MaxLength > 0 && filter.MinLength > filter.MaxLength {
+		issues = append(issues, LintIssue{path, fmt.Sprintf("minLength %d is greater than maxLength %d", filter.MinLength, filter.MaxLength)})
+	}
+	lower, upper := filter.Minimum, filter.Maximum
+	exclusive := false
+	if lower == nil && filter.ExclusiveMinimum != nil {
+		lower, exclusive = filter.ExclusiveMinimum, true
+	}
+	if upper == nil && filter.ExclusiveMaximum != nil {
+		upper, exclusive = filter.ExclusiveMaximum, true
+	}
+	if contradictoryBounds(lower, upper, exclusive) {
+		issues = append(issues, LintIssue{path, fmt.Sprintf("minimum %v is not below maximum %v, no value can satisfy it", lower, upper)})
+	}
+	issues = append(issues, lintFilter(path+".not", filter.Not)...)
+	issues = append(issues, lintFilter(path+".contains", filter.Contains)...)
+	return issues
+}
+
+// contradictoryBounds tells if a lower bound exceeds an upper bound, both numbers or both dates.
+// Exclusive bounds can't be equal either.
+func contradictoryBounds(lower, upper StringOrInteger, exclusive bool) bool {
+	if lower == nil || upper == nil {
+		return false
+	}
+	cmp, ok := compareBounds(lower, upper)
+	if !ok {
+		return false
+	}
+	return cmp > 0 || (exclusive && cmp == 0)
+}
+
+func compareBounds(lower, upper StringOrInteger) (int, bool) {
+	if a, ok := boundNumber(lower); ok {
+		b, ok := boundNumber(upper)
+		switch {
+		case !ok:
+			return 0, false
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		}
+		return 0, true
+	}
+	a, okA := lower.(string)
+	b, okB := upper.(string)
+	if !okA || !okB {
+		return 0, false
+	}
+	ta, errA := parseBoundDate(a)
+	tb, errB := parseBoundDate(b)
+	if errA != nil || errB != nil {
+		return 0, false
+	}
+	switch {
+	case ta.Before(tb):
+		return -1, true
+	case ta.After(tb):
+		return 1, true
+	}
+	return 0, true
+}
+
+func boundNumber(bound StringOrInteger) (float64, bool) {
+	switch n := bound.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// parseBoundDate accepts the date formats accepted by the date filters of the validator
+func parseBoundDate(date string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, date)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-1-2", date)
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gataca-io/vui-core/testdata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	for _, definition := range []string{testdata.GroupPresentationDefinition, testdata.BasicPresentationDefinition, testdata.V2PresentationDefinition} {
+		var presDef PresentationDefinitionHolder
+		assert.NoError(t, json.Unmarshal([]byte(definition), &presDef))
+		issues, err := Lint(&presDef.PresentationDefinition)
+		assert.NoError(t, err)
+		assert.Empty(t, issues)
+	}
+
+	var presDef PresentationDefinition
+	assert.NoError(t, json.Unmarshal([]byte(testdata.MultiGroupPresentationDefinition), &presDef))
+	issues, err := Lint(&presDef)
+	assert.NoError(t, err)
+	assert.Empty(t, issues)
+
+	presDef.InputDescriptors[1].ID = presDef.InputDescriptors[0].ID
+	presDef.InputDescriptors[2].Group = nil
+	presDef.SubmissionRequirements[0].From = "Z"
+	fields := presDef.InputDescriptors[0].Constraints.Fields
+	fields[0].Path[0] = "$.credentialSubject[?(@.age >"
+	fields[0].Filter.Pattern = "did:example:(123"
+	fields[1].Filter.MinLength, fields[1].Filter.MaxLength = 12, 10
+	fields[2].Filter = &Filter{Type: "number", Minimum: float64(18), ExclusiveMaximum: float64(18)}
+	presDef.InputDescriptors[3].Constraints.Fields[0].Filter = &Filter{Type: "string", Format: "date", Minimum: "2020-01-01", Maximum: "1999-5-16"}
+
+	issues, err = Lint(&presDef)
+	assert.Equal(t, ErrInvalidDefinition, err)
+	paths := []string{}
+	for _, issue := range issues {
+		paths = append(paths, issue.Path)
+	}
+	assert.ElementsMatch(t, []string{
+		"$.input_descriptors[1].id",
+		"$.input_descriptors[2].group",
+		"$.input_descriptors[0].constraints.fields[0].path[0]",
+		"$.input_descriptors[0].constraints.fields[0].filter.pattern",
+		"$.input_descriptors[0].constraints.fields[1].filter",
+		"$.input_descriptors[0].constraints.fields[2].filter",
+		"$.input_descriptors[3].constraints.fields[0].filter",
+		"$.submission_requirements[0].from",
+		"$.submission_requirements[1].from",
+	}, paths)
+
+	tenant := &TenantConfig{AdvancedDefinition: &presDef}
+	_, err = tenant.Lint()
+	assert.Equal(t, ErrInvalidDefinition, err)
+	_, err = (&TenantConfig{}).Lint()
+	assert.NoError(t, err)
+}
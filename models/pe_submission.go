@@ -12,7 +12,22 @@ type PresentationSubmission struct {
 }
 
 type Descriptor struct {
-	ID     string           `json:"id" validate:"required"`
-	Path   string           `json:"path" validate:"required"`
-	Format CredentialFormat `json:"format" validate:"required"`
+	ID         string           `json:"id" validate:"required"`
+	Path       string           `json:"path" validate:"required"`
+	Format     CredentialFormat `json:"format" validate:"required"`
+	PathNested *Descriptor      `json:"path_nested,omitempty"` //Evaluated on the document found at Path, decoded with its Format
+}
+
+// IsPresentation tells if the format describes a presentation, which can nest the credentials of a path_nested descriptor
+func (f CredentialFormat) IsPresentation() bool {
+	return f == CredentialFormat(JWTVP) || f == CredentialFormat(LDPVP)
+}
+
+// FullPath returns the paths of every nesting level of the descriptor, from the outermost one
+func (d *Descriptor) FullPath() []string {
+	paths := []string{}
+	for level := d; level != nil; level = level.PathNested {
+		paths = append(paths, level.Path)
+	}
+	return paths
 }
@@ -0,0 +1,26 @@
+package models
+
+// VerificationPolicy customizes how the validations of a presentation exchange are enforced.
+// It can be set for a whole tenant, being applied to all the exchanges created from its configuration.
+type VerificationPolicy struct {
+	ToleratedStatuses []string `json:"toleratedStatuses,omitempty" example:"PENDING" description:"Credential statuses accepted with a warning instead of failing the validation"`
+	CollectAllErrors  bool     `json:"collectAllErrors,omitempty" example:"true" description:"Keep running the validations after the first failure, to report all the problems found"`
+}
+
+// IsStatusTolerated returns if a credential with the given status must be accepted with a warning
+func (vp *VerificationPolicy) IsStatusTolerated(status string) bool {
+	if vp == nil {
+		return false
+	}
+	for _, tolerated := range vp.ToleratedStatuses {
+		if tolerated == status {
+			return true
+		}
+	}
+	return false
+}
+
+// CollectsAllErrors returns if the validations must go on after the first failure
+func (vp *VerificationPolicy) CollectsAllErrors() bool {
+	return vp != nil && vp.CollectAllErrors
+}
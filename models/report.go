@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+type CheckState string
+
+const (
+	CheckPassed  CheckState = "passed"
+	CheckFailed  CheckState = "failed"
+	CheckSkipped CheckState = "skipped"
+	CheckWarning CheckState = "warning"
+)
+
+// DescriptorReport details the checks performed on a submitted credential against its input descriptor
+type DescriptorReport struct {
+	DescriptorId string        `json:"descriptorId" description:"Input descriptor the credential was submitted for" example:"banking_input"`
+	CredentialId string        `json:"credentialId,omitempty" description:"Id of the submitted credential" example:"cred:gatc:exampleABC123"`
+	Path         string        `json:"path,omitempty" description:"Path of the credential in the presentation, with the levels of path_nested separated by >" example:"$.verifiableCredential[0]"`
+	Status       CheckState    `json:"status" description:"Overall outcome of the credential validation" example:"passed"`
+	Checks       []CheckReport `json:"checks" description:"Checks performed on the credential, in order"`
+}
+
+// CheckReport is the outcome of a single check performed on a submitted credential
+type CheckReport struct {
+	Check    string        `json:"check" description:"Name of the check, as reported in the flat list of checks" example:"credentialStatus"`
+	Status   CheckState    `json:"status" description:"Outcome of the check" example:"failed"`
+	Code     string        `json:"code,omitempty" description:"Machine readable code of the error making the check fail" example:"STATUS_REVOKED"`
+	Path     string        `json:"path,omitempty" description:"Path of the credential involved in the check" example:"$.credentialStatus"`
+	Messages []string      `json:"messages,omitempty" description:"Errors and warnings raised by the check"`
+	Duration time.Duration `json:"duration" description:"Time spent on the check, in nanoseconds" example:"1500000"`
+}
+
+func NewDescriptorReport(descriptorId, credentialId, path string) *DescriptorReport {
+	return &DescriptorReport{
+		DescriptorId: descriptorId,
+		CredentialId: credentialId,
+		Path:         path,
+		Status:       CheckPassed,
+		Checks:       []CheckReport{},
+	}
+}
+
+// AddCheck records the outcome of a check, which fails with an error or is a warning when it raised warning messages
+func (r *DescriptorReport) AddCheck(check, path string, duration time.Duration, err error, messages []string) {
+	report := CheckReport{
+		Check:    check,
+		Status:   CheckPassed,
+		Path:     path,
+		Messages: messages,
+		Duration: duration,
+	}
+	switch {
+	case err != nil:
+		report.Status = CheckFailed
+		report.Code = ErrorCode(err)
+		r.Status = CheckFailed
+	case len(messages) > 0:
+		report.Status = CheckWarning
+		if r.Status == CheckPassed {
+			r.Status = CheckWarning
+		}
+	}
+	r.Checks = append(r.Checks, report)
+}
+
+// Skip records checks that weren't performed, like the ones following a failed check
+func (r *DescriptorReport) Skip(checks ...string) {
+	for _, check := range checks {
+		r.Checks = append(r.Checks, CheckReport{Check: check, Status: CheckSkipped})
+	}
+	if len(r.Checks) == len(checks) {
+		r.Status = CheckSkipped
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescriptorReport_AddCheck(t *testing.T) {
+	report := NewDescriptorReport("banking_input", "cred:example:1", "$.verifiableCredential[0]")
+	report.AddCheck("credentialSchema", "$.credentialSchema", time.Millisecond, nil, nil)
+	assert.Equal(t, CheckPassed, report.Status)
+
+	report.AddCheck("credentialStatus", "$.credentialStatus", time.Millisecond, nil, []string{"Credential status not available."})
+	assert.Equal(t, CheckWarning, report.Status)
+	assert.Equal(t, CheckWarning, report.Checks[1].Status)
+
+	report.AddCheck("constraints", "", time.Millisecond, ErrMissingConstraint, []string{"Field constraint not validated"})
+	assert.Equal(t, CheckFailed, report.Status)
+	assert.Equal(t, "MISSING_CONSTRAINT", report.Checks[2].Code)
+
+	skipped := NewDescriptorReport("employment_input", "cred:example:2", "$.verifiableCredential[1]")
+	skipped.Skip("credentialSchema", "constraints")
+	assert.Equal(t, CheckSkipped, skipped.Status)
+	assert.Equal(t, 2, len(skipped.Checks))
+}
+
+func TestErrorCode(t *testing.T) {
+	assert.Equal(t, "STATUS_SUSPENDED", ErrorCode(ErrStatusSuspended))
+	assert.Equal(t, "HOLDER_BINDING", ErrorCode(ErrHolderBinding))
+	assert.Equal(t, "UNKNOWN", ErrorCode(errors.New("connection refused")))
+}
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/gataca-io/vui-core/constant"
+)
 
 type VerifiableStatus struct {
 	Description          string                       `json:"description,omitempty"`
@@ -22,3 +26,21 @@ type Claim struct {
 	Id            string `json:"id"`
 	StatusReason  string `json:"statusReason,omitempty"`
 }
+
+var statusErrors = map[string]error{
+	constant.CredentialStatusPending:   ErrStatusPending,
+	constant.CredentialStatusRevoked:   ErrStatusRevoked,
+	constant.CredentialStatusDeleted:   ErrStatusDeleted,
+	constant.CredentialStatusExpired:   ErrStatusExpired,
+	constant.CredentialStatusInvalid:   ErrStatusInvalid,
+	constant.CredentialStatusClaimed:   ErrStatusClaimed,
+	constant.CredentialStatusSuspended: ErrStatusSuspended,
+}
+
+// GetStatusError returns the error describing a credential status other than issued
+func GetStatusError(status string) error {
+	if err, ok := statusErrors[status]; ok {
+		return err
+	}
+	return ErrStatusNotValid
+}
@@ -16,6 +16,7 @@ type TenantConfig struct {
 	DataAgreementTemplate *DataAgreement          `json:"dataAgreementTemplate" description:"Template of the Data Agreement associated with this service"`
 	ServicePurpose        string                  `json:"service" description:"Description of the service that is being provided with this QR"`
 	AdvancedDefinition    *PresentationDefinition `json:"advancedDefinition" description:"Presentation exchange definition at an advanced level for expert admin users"`
+	Policy                *VerificationPolicy     `json:"policy,omitempty" description:"Policy customizing how the validations of the tenant's presentations are enforced"`
 }
 
 type CredentialRequest struct {
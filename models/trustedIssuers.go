@@ -1,5 +1,10 @@
 package models
 
+import (
+	"strings"
+	"time"
+)
+
 type TrustedIssuerList struct {
 	Proof          *Proof          `json:"proof,omitempty"`
 	TrustedIssuers []TrustedIssuer `json:"trustedIssuers,omitempty"`
@@ -74,6 +79,40 @@ type Accreditations struct {
 	ValidFrom        *TimeWithFormat     `json:"validFrom,omitempty"`
 }
 
+// HasDID tells if the DID belongs to the issuer, ignoring the fragments of the keys it may be listed with
+func (ti *TrustedIssuer) HasDID(did string) bool {
+	did = strings.Split(did, "#")[0]
+	for _, listed := range ti.Dids {
+		if strings.Split(listed, "#")[0] == did {
+			return true
+		}
+	}
+	return false
+}
+
+// Accredits tells if the accreditation grants, at the given time, at least the level of trust for any of the schemas.
+// Accreditations not restricted to a schema accredit all of them.
+func (a *Accreditations) Accredits(level int, schemas []string, at time.Time) bool {
+	if a == nil || a.LevelOfTrust < level {
+		return false
+	}
+	if a.ValidFrom != nil && !a.ValidFrom.IsZero() && a.ValidFrom.After(at) {
+		return false
+	}
+	if a.ExpirationDate != nil && !a.ExpirationDate.IsZero() && a.ExpirationDate.Before(at) {
+		return false
+	}
+	if a.CredentialSchema == "" {
+		return true
+	}
+	for _, schema := range schemas {
+		if schema == a.CredentialSchema {
+			return true
+		}
+	}
+	return false
+}
+
 type CredentialEvidence struct {
 	EvidenceDocs     []string `json:"evidenceDocuments,omitempty"`
 	DocumentPresence string   `json:"documentPresence,omitempty"`
@@ -45,6 +45,8 @@ type VerificationResult struct {
 	Checks   []string `json:"checks" description:"Security checks performed" example:"['proof']"`
 	Warnings []string `json:"warnings" description:"Warning messages to include about the validation" example:"['Context not verified']"`
 	Errors   []string `json:"errors" description:"Resulting errors on the validation. Should be empty if the validation is successful." example:"[]"`
+
+	Descriptors []DescriptorReport `json:"descriptors,omitempty" description:"Checks performed on each submitted credential"`
 }
 
 func (v *VerificationResult) Valid() bool {
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"container/list"
+	"regexp"
+	"sync"
+
+	"github.com/gataca-io/vui-core/log"
+	"github.com/gataca-io/vui-core/models"
+	"github.com/labstack/echo/v4"
+	"github.com/ohler55/ojg/jp"
+)
+
+// maxCachedExpressions bounds the compiled expressions kept in memory, as definitions are provided by the tenants
+const maxCachedExpressions = 2048
+
+// expressionCache keeps expressions compiled by their source, so the definitions in use are only compiled once.
+// When full, the least recently used expression is evicted, so a burst of new definitions doesn't make the ones in
+// use compile again.
+type expressionCache struct {
+	mu       sync.Mutex
+	compiled map[string]*list.Element
+	recent   *list.List
+	compile  func(source string) (interface{}, error)
+}
+
+// cachedExpression is an entry of the recently used list of the cache
+type cachedExpression struct {
+	source string
+	expr   interface{}
+}
+
+func newExpressionCache(compile func(source string) (interface{}, error)) *expressionCache {
+	return &expressionCache{compiled: map[string]*list.Element{}, recent: list.New(), compile: compile}
+}
+
+func (c *expressionCache) get(source string) (interface{}, error) {
+	if expr, ok := c.lookup(source); ok {
+		return expr, nil
+	}
+	expr, err := c.compile(source)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	//Keep the expression compiled concurrently, if any, so every caller shares the same one
+	if elem, ok := c.compiled[source]; ok {
+		c.recent.MoveToFront(elem)
+		return elem.Value.(*cachedExpression).expr, nil
+	}
+	c.compiled[source] = c.recent.PushFront(&cachedExpression{source: source, expr: expr})
+	if c.recent.Len() > maxCachedExpressions {
+		oldest := c.recent.Remove(c.recent.Back()).(*cachedExpression)
+		delete(c.compiled, oldest.source)
+	}
+	return expr, nil
+}
+
+// lookup returns the compiled expression of the source, marking it as the most recently used
+func (c *expressionCache) lookup(source string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.compiled[source]
+	if !ok {
+		return nil, false
+	}
+	c.recent.MoveToFront(elem)
+	return elem.Value.(*cachedExpression).expr, true
+}
+
+var pathCache = newExpressionCache(func(source string) (interface{}, error) {
+	return jp.ParseString(source)
+})
+
+var patternCache = newExpressionCache(func(source string) (interface{}, error) {
+	return regexp.Compile(source)
+})
+
+// compilePath returns the compiled JSONPath expression
+func compilePath(path string) (jp.Expr, error) {
+	expr, err := pathCache.get(path)
+	if err != nil {
+		return nil, err
+	}
+	return expr.(jp.Expr), nil
+}
+
+// compilePattern returns the compiled regular expression of a filter pattern
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	expr, err := patternCache.get(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return expr.(*regexp.Regexp), nil
+}
+
+// compileDefinition compiles the field paths and the filter patterns of a definition before its submission
+// is evaluated, so the credentials validated concurrently find them compiled
+func compileDefinition(ctx echo.Context, pd *models.PresentationDefinition) {
+	for _, descriptor := range pd.InputDescriptors {
+		if descriptor.Constraints == nil {
+			continue
+		}
+		for _, field := range descriptor.Constraints.Fields {
+			for _, path := range field.Path {
+				if _, err := compilePath(path); err != nil {
+					log.CWarnf(ctx, "Cannot parse path %s of descriptor %s: %s", path, descriptor.ID, err.Error())
+				}
+			}
+			compileFilter(ctx, descriptor.ID, field.Filter)
+		}
+	}
+}
+
+func compileFilter(ctx echo.Context, descriptorId string, filter *models.Filter) {
+	if filter == nil {
+		return
+	}
+	if filter.Pattern != "" {
+		if _, err := compilePattern(filter.Pattern); err != nil {
+			log.CWarnf(ctx, "Cannot compile pattern %s of descriptor %s: %s", filter.Pattern, descriptorId, err.Error())
+		}
+	}
+	compileFilter(ctx, descriptorId, filter.Not)
+	compileFilter(ctx, descriptorId, filter.Contains)
+}
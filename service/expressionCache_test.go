@@ -0,0 +1,54 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpressionCache(t *testing.T) {
+	compiled := 0
+	cache := newExpressionCache(func(source string) (interface{}, error) {
+		compiled++
+		return compilePath(source)
+	})
+	first, err := cache.get("$.credentialSubject.id")
+	assert.NoError(t, err)
+	second, err := cache.get("$.credentialSubject.id")
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, compiled)
+
+	_, err = cache.get("$.[")
+	assert.Error(t, err)
+	assert.Len(t, cache.compiled, 1)
+
+	for i := 1; i < maxCachedExpressions; i++ {
+		_, err = cache.get(fmt.Sprintf("$.credentialSubject.claim%d", i))
+		assert.NoError(t, err)
+	}
+	assert.Len(t, cache.compiled, maxCachedExpressions)
+
+	//The expressions in use are kept when full, evicting the least recently used
+	_, err = cache.get("$.credentialSubject.id")
+	assert.NoError(t, err)
+	_, err = cache.get("$.credentialSubject.other")
+	assert.NoError(t, err)
+	assert.Len(t, cache.compiled, maxCachedExpressions)
+	assert.Equal(t, maxCachedExpressions, cache.recent.Len())
+	assert.Contains(t, cache.compiled, "$.credentialSubject.id")
+	assert.NotContains(t, cache.compiled, "$.credentialSubject.claim1")
+	assert.Equal(t, 2+maxCachedExpressions, compiled) //Every source, the invalid one included, compiled only once
+}
+
+func TestCompilePattern(t *testing.T) {
+	pattern, err := compilePattern("^did:example:")
+	assert.NoError(t, err)
+	assert.True(t, pattern.MatchString("did:example:123"))
+	cached, _ := compilePattern("^did:example:")
+	assert.Same(t, pattern, cached)
+
+	_, err = compilePattern("did:example:(123")
+	assert.Error(t, err)
+}
@@ -9,21 +9,72 @@ import (
 	"github.com/xeipuuv/gojsonschema"
 )
 
-type jsonValidator struct{}
+// jsonValidator validates documents with the schemas resolved by its registry. Without registry, schemas are
+// loaded from their reference on every validation.
+type jsonValidator struct {
+	registry SchemaRegistry
+}
+
+// NewJSONValidator creates a validator resolving the schemas referenced by documents with the given registry
+func NewJSONValidator(registry SchemaRegistry) JSONValidator {
+	return &jsonValidator{registry: registry}
+}
 
 // Validate any document with a reference to its json schema or having a string of its json schema.
 func (j *jsonValidator) Validate(document models.JSONSchema) error {
 	if document.IsRef() {
-		return validateWithJSONLoader(gojsonschema.NewReferenceLoader(document.GetSchemaRef()), gojsonschema.NewGoLoader(document))
+		return j.validateWithSchemaRef(document, document.GetSchemaRef())
 	}
 	return validateWithJSONLoader(gojsonschema.NewStringLoader(document.GetSchema()), gojsonschema.NewGoLoader(document))
 }
 
+// ValidateWithRef validates a document with the schema it references or, if it references none, with the given
+// schema, either the schema itself or its URI
 func (j *jsonValidator) ValidateWithRef(document models.JSONSchema, ref string) error {
-	if document.IsRef() {
-		return validateWithJSONLoader(gojsonschema.NewReferenceLoader(document.GetSchemaRef()), gojsonschema.NewGoLoader(document))
+	if document.IsRef() && document.GetSchemaRef() != "" {
+		return j.validateWithSchemaRef(document, document.GetSchemaRef())
+	}
+	if isJSON(ref) {
+		return validateWithJSONLoader(gojsonschema.NewStringLoader(ref), gojsonschema.NewGoLoader(document))
+	}
+	return j.validateWithSchemaRef(document, ref)
+}
+
+func (j *jsonValidator) validateWithSchemaRef(document models.JSONSchema, uri string) error {
+	if j.registry == nil {
+		return validateWithJSONLoader(gojsonschema.NewReferenceLoader(uri), gojsonschema.NewGoLoader(document))
+	}
+	//The schema and the ones it references are all resolved by the registry, which decides if they can be fetched
+	return validateWithJSONLoader(registryLoaderFactory{j.registry}.New(uri), gojsonschema.NewGoLoader(document))
+}
+
+// registryLoaderFactory creates the loaders of the schemas referenced by $ref while compiling a schema, so
+// references are resolved by the registry instead of fetched from their URI
+type registryLoaderFactory struct {
+	registry SchemaRegistry
+}
+
+func (f registryLoaderFactory) New(source string) gojsonschema.JSONLoader {
+	return &registryLoader{JSONLoader: gojsonschema.NewReferenceLoader(source), registry: f.registry}
+}
+
+// registryLoader loads a schema by reference from the registry, keeping the reference to resolve the $ref inside it
+type registryLoader struct {
+	gojsonschema.JSONLoader
+	registry SchemaRegistry
+}
+
+func (l *registryLoader) LoadJSON() (interface{}, error) {
+	uri := strings.SplitN(l.JsonSource().(string), "#", 2)[0]
+	schema, err := l.registry.GetSchema(uri)
+	if err != nil {
+		return nil, err
 	}
-	return validateWithJSONLoader(gojsonschema.NewStringLoader(ref), gojsonschema.NewGoLoader(document))
+	return gojsonschema.NewBytesLoader(schema).LoadJSON()
+}
+
+func (l *registryLoader) LoaderFactory() gojsonschema.JSONLoaderFactory {
+	return registryLoaderFactory{l.registry}
 }
 
 // Validate exists to hide gojsonschema logic within this file
@@ -71,3 +122,8 @@ func isJSON(str string) bool {
 	var js json.RawMessage
 	return json.Unmarshal([]byte(str), &js) == nil
 }
+
+// validateGoValues validates a go value against a schema also given as a go value, like the filters of constraints
+func validateGoValues(schema, document interface{}) error {
+	return validateWithJSONLoader(gojsonschema.NewGoLoader(schema), gojsonschema.NewGoLoader(document))
+}
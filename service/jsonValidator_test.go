@@ -30,6 +30,7 @@ func TestPresentationDefinitionSchema(t *testing.T) {
 
 		// Validate against presDefSchema
 		assert.NoError(t, jVal.ValidateStrings(testdata.PresentationDefinitionSchema, testdata.BasicPresentationDefinition))
+		assert.NoError(t, jVal.ValidateStrings(testdata.PresentationDefinitionSchema, testdata.V2PresentationDefinition))
 	})
 }
 
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/gataca-io/vui-core/log"
+	"github.com/gataca-io/vui-core/models"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	proofPurposeAssertion      = "assertionMethod"
+	proofPurposeAuthentication = "authentication"
+)
+
+// verifyCredentialProof checks the proof of a credential. Credentials received as JWT are verified with the keys
+// of their issuer DID, while the rest of proofs are delegated to the SSI service.
+func (vs *ValidatorServiceDIF) verifyCredentialProof(ctx echo.Context, vc *models.VerifiableCredential, requesterVMethod string) error {
+	proof := findJWTProof(vc.GetProofs())
+	if proof == nil {
+		_, err := vs.ssiS.VerifyCredential(ctx, vc, requesterVMethod, false)
+		return err
+	}
+	signed, err := models.ParseJWTCredential(proof.Jws)
+	if err != nil {
+		log.CErrorf(ctx, "Cannot decode the JWT of credential %s", vc.Id)
+		return err
+	}
+	if !sameDocument(signed, vc) {
+		log.CErrorf(ctx, "Credential %s doesn't match the content of its JWT", vc.Id)
+		return models.ErrInvalidSignature
+	}
+	return vs.verifyJWS(ctx, proof, vc.Issuer, proofPurposeAssertion)
+}
+
+// verifyPresentationProof checks the proof of a presentation. Presentations received as JWT are verified with the keys
+// of their holder DID, while linked data proofs are delegated to the SSI service.
+func (vs *ValidatorServiceDIF) verifyPresentationProof(ctx echo.Context, vp *models.VerifiablePresentation, requesterVMethod string) error {
+	proof := findJWTProof(vp.GetProofs(), vs.factors...)
+	if proof == nil {
+		return vs.ssiS.VerifyPresentation(ctx, vp, requesterVMethod)
+	}
+	signed, err := models.ParseJWTPresentation(proof.Jws)
+	if err != nil {
+		log.CError(ctx, "Cannot decode the JWT of the presentation")
+		return err
+	}
+	if !sameDocument(signed, vp) {
+		log.CError(ctx, "Presentation doesn't match the content of its JWT")
+		return models.ErrInvalidSignature
+	}
+	holder := ""
+	if vp.Holder != nil {
+		holder = *vp.Holder
+	}
+	return vs.verifyJWS(ctx, proof, holder, proofPurposeAuthentication)
+}
+
+// verifyJWS checks the signature of a JWT proof with the key it references, which must belong to the DID of the signer
+// and be authorized by it for the purpose of the proof
+func (vs *ValidatorServiceDIF) verifyJWS(ctx echo.Context, proof *models.Proof, signer, purpose string) error {
+	jws, err := models.ParseJWS(proof.Jws)
+	if err != nil {
+		log.CError(ctx, "Cannot decode the JWS of the proof")
+		return err
+	}
+	if signer == "" {
+		log.CErrorf(ctx, "Proof with key %s has no signer to verify it against", proof.VerificationMethod)
+		return models.ErrMissingKey
+	}
+	did := strings.SplitN(proof.VerificationMethod, "#", 2)[0]
+	if did != signer {
+		log.CErrorf(ctx, "Key %s doesn't belong to the signer %s", proof.VerificationMethod, signer)
+		return models.ErrMissingKey
+	}
+	if vs.didS == nil {
+		log.CErrorf(ctx, "No DID service available to resolve %s", did)
+		return models.ErrDIDNotAvailable
+	}
+	doc, err := vs.didS.GetDID(ctx, did)
+	if err != nil || doc == nil {
+		log.CErrorf(ctx, "Cannot resolve DID %s: %v", did, err)
+		return models.ErrDIDNotAvailable
+	}
+	key := findVerificationKey(doc, proof.VerificationMethod, purpose)
+	if key == nil {
+		log.CErrorf(ctx, "Key %s not found in the %s methods of DID %s", proof.VerificationMethod, purpose, did)
+		return models.ErrMissingKey
+	}
+	publicKey, err := parsePublicKey(key)
+	if err != nil {
+		log.CErrorf(ctx, "Cannot use key %s to verify signatures: %v", key.Id, err)
+		return err
+	}
+	err = verifySignature(jws.Header.Alg, publicKey, []byte(jws.SigningInput()), jws.Signature)
+	if err != nil {
+		log.CErrorf(ctx, "Signature with algorithm %s of key %s not valid: %v", jws.Header.Alg, key.Id, err)
+		return err
+	}
+	return nil
+}
+
+// findJWTProof returns the proof holding the JWT the document was received with, if any. Proofs signed by keys
+// matching factors prove second factors instead, so they are skipped.
+func findJWTProof(proofs *models.SSIProof, factors ...*regexp.Regexp) *models.Proof {
+	if proofs == nil || proofs.GetProof() == nil {
+		return nil
+	}
+	for _, p := range *proofs.GetProof() {
+		if p.Type == models.ProofTypeJWT && p.Jws != "" && !isFactorProof(&p, factors) {
+			proof := p
+			return &proof
+		}
+	}
+	return nil
+}
+
+// findLinkedDataProof returns the first proof that isn't a JWT, the one the SSI service verifies documents with
+func findLinkedDataProof(proofs *models.SSIProof) *models.Proof {
+	if proofs == nil || proofs.GetProof() == nil {
+		return nil
+	}
+	for _, p := range *proofs.GetProof() {
+		if p.Type != models.ProofTypeJWT {
+			proof := p
+			return &proof
+		}
+	}
+	return nil
+}
+
+// sameDocument tells if a document still has the content decoded from its JWT
+func sameDocument(signed, document interface{}) bool {
+	expected, err := json.Marshal(signed)
+	if err != nil {
+		return false
+	}
+	received, err := json.Marshal(document)
+	if err != nil {
+		return false
+	}
+	return string(expected) == string(received)
+}
+
+// findVerificationKey looks for a key of the DID document by its id among the verification methods of the given purpose,
+// assertionMethod for credentials and authentication for presentations. Keys with relative ids are matched by their fragment.
+func findVerificationKey(doc *models.DIDDocument, keyId, purpose string) *models.PublicKey {
+	i := strings.Index(keyId, "#")
+	if i < 0 {
+		return nil
+	}
+	fragment := keyId[i:]
+	matches := func(id string) bool {
+		return id == keyId || id == fragment
+	}
+	var methods []models.VerificationMethod
+	switch purpose {
+	case proofPurposeAssertion:
+		methods = doc.Assertion
+	case proofPurposeAuthentication:
+		methods = doc.Authentication
+	}
+	for _, method := range methods {
+		if !matches(method.GetId()) {
+			continue
+		}
+		if method.Method != nil {
+			return method.Method
+		}
+		for _, key := range doc.GetVerificationMethods() {
+			if matches(key.Id) {
+				return key
+			}
+		}
+	}
+	return nil
+}
+
+// parsePublicKey obtains the public key of a verification method expressed as JWK, base58 or PEM
+func parsePublicKey(key *models.PublicKey) (crypto.PublicKey, error) {
+	switch {
+	case key.KeyJwk != nil:
+		return parseJWK(key.KeyJwk)
+	case key.KeyPem != "":
+		block, _ := pem.Decode([]byte(key.KeyPem))
+		if block == nil {
+			return nil, models.ErrInvalidFormat
+		}
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case key.KeyB58 != "" && (key.Type == models.TypeEd25519 || key.Type == models.TypeJCS_Ed):
+		decoded := base58.Decode(key.KeyB58)
+		if len(decoded) != ed25519.PublicKeySize {
+			return nil, models.ErrInvalidFormat
+		}
+		return ed25519.PublicKey(decoded), nil
+	}
+	return nil, models.ErrUnsupportedAlgorithm
+}
+
+func parseJWK(jwk *models.JWK) (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "OKP":
+		x, err := decodeJWKParam(jwk.X)
+		if err != nil || jwk.Curve != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, models.ErrUnsupportedAlgorithm
+		}
+		return ed25519.PublicKey(x), nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, models.ErrUnsupportedAlgorithm
+		}
+		x, errX := decodeJWKParam(jwk.X)
+		y, errY := decodeJWKParam(jwk.Y)
+		if errX != nil || errY != nil {
+			return nil, models.ErrInvalidFormat
+		}
+		publicKey := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, models.ErrInvalidFormat
+		}
+		return publicKey, nil
+	case "RSA":
+		n, errN := decodeJWKParam(jwk.N)
+		e, errE := decodeJWKParam(jwk.E)
+		if errN != nil || errE != nil || len(e) == 0 {
+			return nil, models.ErrInvalidFormat
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}
+	return nil, models.ErrUnsupportedAlgorithm
+}
+
+func decodeJWKParam(param string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(param, "="))
+}
+
+// verifySignature checks a JWS signature of the given JWA algorithm
+func verifySignature(alg string, publicKey crypto.PublicKey, input, signature []byte) error {
+	switch alg {
+	case "EdDSA":
+		key, ok := publicKey.(ed25519.PublicKey)
+		if !ok {
+			return models.ErrUnsupportedAlgorithm
+		}
+		if !ed25519.Verify(key, input, signature) {
+			return models.ErrInvalidSignature
+		}
+		return nil
+	case "ES256", "ES384", "ES512":
+		key, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return models.ErrUnsupportedAlgorithm
+		}
+		//Signatures are the concatenation of R and S, each padded to the size of the curve
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return models.ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest(hashOf(alg), input), r, s) {
+			return models.ErrInvalidSignature
+		}
+		return nil
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+		key, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return models.ErrUnsupportedAlgorithm
+		}
+		hash := hashOf(alg)
+		var err error
+		if strings.HasPrefix(alg, "PS") {
+			err = rsa.VerifyPSS(key, hash, digest(hash, input), signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			err = rsa.VerifyPKCS1v15(key, hash, digest(hash, input), signature)
+		}
+		if err != nil {
+			return models.ErrInvalidSignature
+		}
+		return nil
+	}
+	return models.ErrUnsupportedAlgorithm
+}
+
+func hashOf(alg string) crypto.Hash {
+	switch alg[2:] {
+	case "384":
+		return crypto.SHA384
+	case "512":
+		return crypto.SHA512
+	}
+	return crypto.SHA256
+}
+
+func digest(hash crypto.Hash, input []byte) []byte {
+	h := hash.New()
+	h.Write(input)
+	return h.Sum(nil)
+}
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/gataca-io/vui-core/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	jwtIssuer = "did:example:issuer"
+	jwtHolder = "did:example:holder"
+)
+
+const jwtPresentationDefinition = `{
+	"id": "jwt-definition",
+	"input_descriptors": [
+		{
+			"id": "email_input",
+			"schema": [{"uri": "https://example.com/schemas/email.json"}],
+			"constraints": {
+				"fields": [
+					{"path": ["$.credentialSubject.email"], "filter": {"type": "string", "pattern": "@example.com$"}}
+				]
+			}
+		}
+	]
+}`
+
+func encodeJWT(t *testing.T, header, claims map[string]interface{}, sign func(input []byte) []byte) string {
+	headerBytes, err := json.Marshal(header)
+	assert.NoError(t, err)
+	claimsBytes, err := json.Marshal(claims)
+	assert.NoError(t, err)
+	input := base64.RawURLEncoding.EncodeToString(headerBytes) + "." + base64.RawURLEncoding.EncodeToString(claimsBytes)
+	return input + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(input)))
+}
+
+func createJWTCredential(t *testing.T, key ed25519.PrivateKey, exp time.Time) string {
+	return encodeJWT(t, map[string]interface{}{"alg": "EdDSA", "kid": jwtIssuer + "#key-1"}, map[string]interface{}{
+		"iss": jwtIssuer,
+		"sub": jwtHolder,
+		"jti": "cred:example:jwt",
+		"nbf": time.Now().Add(-time.Hour).Unix(),
+		"exp": exp.Unix(),
+		"vc": map[string]interface{}{
+			"type":              []string{"VerifiableCredential", "EmailCredential"},
+			"credentialSchema":  map[string]interface{}{"id": "https://example.com/schemas/email.json", "type": "JsonSchemaValidator2018"},
+			"credentialSubject": map[string]interface{}{"email": "holder@example.com"},
+		},
+	}, func(input []byte) []byte {
+		return ed25519.Sign(key, input)
+	})
+}
+
+func createJWTPresentation(t *testing.T, holderKey *ecdsa.PrivateKey, credential string, descriptor map[string]interface{}, extra ...map[string]interface{}) string {
+	claims := map[string]interface{}{
+		"iss":   jwtHolder,
+		"aud":   "did:example:verifier",
+		"nonce": "challenge",
+		"vp": map[string]interface{}{
+			"type":                 []string{"VerifiablePresentation"},
+			"verifiableCredential": []string{credential},
+			"presentation_submission": map[string]interface{}{
+				"id":             "jwt-submission",
+				"definition_id":  "jwt-definition",
+				"descriptor_map": []map[string]interface{}{descriptor},
+			},
+		},
+	}
+	for _, claim := range extra {
+		for name, value := range claim {
+			claims[name] = value
+		}
+	}
+	return encodeJWT(t, map[string]interface{}{"alg": "ES256", "kid": "#key-1"}, claims, func(input []byte) []byte {
+		hash := sha256.Sum256(input)
+		r, s, err := ecdsa.Sign(rand.Reader, holderKey, hash[:])
+		assert.NoError(t, err)
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature
+	})
+}
+
+func createJWTValidator(t *testing.T, issuerKey ed25519.PublicKey, holderKey *ecdsa.PublicKey) *ValidatorServiceDIF {
+	validator := NewDIFValidatorService(mockedSSIs, WithDidService(&mockDidService{
+		docs: map[string]*models.DIDDocument{
+			jwtIssuer: {
+				Id: jwtIssuer,
+				VerificationMethod: []*models.PublicKey{
+					{Id: jwtIssuer + "#key-1", Type: models.TypeEd25519, KeyB58: base58.Encode(issuerKey)},
+				},
+				Assertion: []models.VerificationMethod{{Reference: jwtIssuer + "#key-1"}},
+			},
+			jwtHolder: {
+				Id: jwtHolder,
+				VerificationMethod: []*models.PublicKey{
+					{Id: "#key-1", Type: models.TypeJWS, KeyJwk: &models.JWK{
+						KeyType: "EC",
+						Curve:   "P-256",
+						X:       base64.RawURLEncoding.EncodeToString(holderKey.X.Bytes()),
+						Y:       base64.RawURLEncoding.EncodeToString(holderKey.Y.Bytes()),
+					}},
+				},
+				Authentication: []models.VerificationMethod{{Reference: "#key-1"}},
+			},
+		},
+	})).(*ValidatorServiceDIF)
+	validator.jVal = mockedJVal
+	return validator
+}
+
+func TestJWTVerifier_ParseCredential(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	token := createJWTCredential(t, key, exp)
+
+	vc := models.VerifiableCredential{}
+	assert.NoError(t, json.Unmarshal([]byte(`"`+token+`"`), &vc))
+	assert.Equal(t, "cred:example:jwt", vc.Id)
+	assert.Equal(t, jwtIssuer, vc.Issuer)
+	assert.Equal(t, jwtHolder, (*vc.CredentialSubject)["id"])
+	assert.Equal(t, "holder@example.com", (*vc.CredentialSubject)["email"])
+	assert.True(t, exp.Equal(vc.ExpirationDate.Time))
+	assert.NotNil(t, vc.IssuanceDate)
+	assert.Equal(t, models.ProofTypeJWT, vc.Proof.Value.Type)
+	assert.Equal(t, jwtIssuer+"#key-1", vc.Proof.Value.VerificationMethod)
+	assert.Equal(t, token, vc.Proof.Value.Jws)
+
+	assert.Error(t, json.Unmarshal([]byte(`"not.a-jwt"`), &vc))
+}
+
+func TestJWTVerifier_FindVerificationKey(t *testing.T) {
+	assertion := &models.PublicKey{Id: jwtIssuer + "#assertion", Type: models.TypeEd25519}
+	embedded := &models.PublicKey{Id: "#embedded", Type: models.TypeEd25519}
+	doc := &models.DIDDocument{
+		Id:                 jwtIssuer,
+		VerificationMethod: []*models.PublicKey{assertion, {Id: jwtIssuer + "#unrelated", Type: models.TypeEd25519}},
+		Assertion:          []models.VerificationMethod{{Reference: "#assertion"}},
+		Authentication:     []models.VerificationMethod{{Method: embedded}},
+	}
+
+	assert.Equal(t, assertion, findVerificationKey(doc, jwtIssuer+"#assertion", proofPurposeAssertion))
+	assert.Equal(t, embedded, findVerificationKey(doc, jwtIssuer+"#embedded", proofPurposeAuthentication))
+	//Keys are only valid for the purposes the DID authorizes them for
+	assert.Nil(t, findVerificationKey(doc, jwtIssuer+"#assertion", proofPurposeAuthentication))
+	assert.Nil(t, findVerificationKey(doc, jwtIssuer+"#unrelated", proofPurposeAssertion))
+	//References must select a key
+	assert.Nil(t, findVerificationKey(doc, jwtIssuer, proofPurposeAssertion))
+	assert.Nil(t, findVerificationKey(doc, jwtIssuer+"#missing", proofPurposeAssertion))
+}
+
+func TestJWTVerifier_VerifyJWSSigner(t *testing.T) {
+	issuerPub, issuerKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	holderKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	validator := createJWTValidator(t, issuerPub, &holderKey.PublicKey)
+	vc := models.VerifiableCredential{}
+	assert.NoError(t, json.Unmarshal([]byte(`"`+createJWTCredential(t, issuerKey, time.Now().Add(time.Hour))+`"`), &vc))
+
+	assert.NoError(t, validator.verifyJWS(nil, vc.Proof.Value, jwtIssuer, proofPurposeAssertion))
+	assert.Equal(t, models.ErrMissingKey, validator.verifyJWS(nil, vc.Proof.Value, "", proofPurposeAssertion))
+	assert.Equal(t, models.ErrMissingKey, validator.verifyJWS(nil, vc.Proof.Value, jwtHolder, proofPurposeAssertion))
+	assert.Equal(t, models.ErrMissingKey, validator.verifyJWS(nil, vc.Proof.Value, jwtIssuer, proofPurposeAuthentication))
+}
+
+func TestDIFValidatorService_ValidateJWTCredential(t *testing.T) {
+	issuerPub, issuerKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	holderKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	validator := createJWTValidator(t, issuerPub, &holderKey.PublicKey)
+	var presentationDefinition models.PresentationDefinition
+	assert.NoError(t, json.Unmarshal([]byte(jwtPresentationDefinition), &presentationDefinition))
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"Valid", createJWTCredential(t, issuerKey, time.Now().Add(time.Hour)), nil},
+		{"Wrong signature", createJWTCredential(t, otherKey, time.Now().Add(time.Hour)), models.ErrInvalidSignature},
+		{"Expired", createJWTCredential(t, issuerKey, time.Now().Add(-time.Hour)), models.ErrCredentialExpired},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			presentation, err := json.Marshal(map[string]interface{}{
+				"type":                 []string{"VerifiablePresentation"},
+				"holder":               jwtHolder,
+				"verifiableCredential": []string{test.token},
+				"presentation_submission": map[string]interface{}{
+					"id":             "jwt-submission",
+					"definition_id":  "jwt-definition",
+					"descriptor_map": []map[string]interface{}{{"id": "email_input", "format": "jwt_vc", "path": "$.verifiableCredential[0]"}},
+				},
+			})
+			assert.NoError(t, err)
+			vp := &models.VerifiablePresentation{}
+			assert.NoError(t, json.Unmarshal(presentation, vp))
+
+			res, err := validator.ValidatePresentationResponse(nil, &presentationDefinition, vp, "")
+			assert.Equal(t, test.err, err)
+			if test.err == nil {
+				assert.Contains(t, res.Checks, CheckCredential)
+				assert.Contains(t, res.Checks, CheckConstraints)
+			}
+		})
+	}
+}
+
+func TestDIFValidatorService_ValidateJWTPresentation(t *testing.T) {
+	issuerPub, issuerKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	holderKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	validator := createJWTValidator(t, issuerPub, &holderKey.PublicKey)
+	var presentationDefinition models.PresentationDefinition
+	assert.NoError(t, json.Unmarshal([]byte(jwtPresentationDefinition), &presentationDefinition))
+
+	token := createJWTPresentation(t, holderKey, createJWTCredential(t, issuerKey, time.Now().Add(time.Hour)), map[string]interface{}{
+		"id": "email_input", "format": "jwt_vc", "path": "$.vp.verifiableCredential[0]",
+	})
+
+	vp := &models.VerifiablePresentation{}
+	assert.NoError(t, json.Unmarshal([]byte(`"`+token+`"`), vp))
+	assert.Equal(t, jwtHolder, *vp.Holder)
+	assert.Equal(t, "challenge", vp.Proof.Value.Challenge)
+	assert.Equal(t, "did:example:verifier", vp.Proof.Value.Domain)
+
+	res, err := validator.ValidatePresentationResponse(nil, &presentationDefinition, vp, "")
+	assert.NoError(t, err)
+	assert.Contains(t, res.Checks, CheckPresentation)
+	assert.Contains(t, res.Checks, CheckCredential)
+
+	(*vp.VerifiableCredential[0].CredentialSubject)["email"] = "forged@example.com"
+	_, err = validator.ValidatePresentationResponse(nil, &presentationDefinition, vp, "")
+	assert.Equal(t, models.ErrInvalidSignature, err)
+}
+
+func TestDIFValidatorService_ValidateJWTPresentationAudience(t *testing.T) {
+	issuerPub, issuerKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	holderKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	validator := createJWTValidator(t, issuerPub, &holderKey.PublicKey)
+	var presentationDefinition models.PresentationDefinition
+	assert.NoError(t, json.Unmarshal([]byte(jwtPresentationDefinition), &presentationDefinition))
+	presentationDefinition.Challenge = "challenge"
+	presentationDefinition.Domain = "did:example:verifier"
+	credential := createJWTCredential(t, issuerKey, time.Now().Add(time.Hour))
+	descriptor := map[string]interface{}{"id": "email_input", "format": "jwt_vc", "path": "$.vp.verifiableCredential[0]"}
+
+	tests := []struct {
+		name string
+		aud  interface{}
+		err  error
+	}{
+		{"Single audience", "did:example:verifier", nil},
+		{"Among audiences", []string{"did:example:other", "did:example:verifier"}, nil},
+		{"Other audiences", []string{"did:example:other", "did:example:another"}, models.ErrInvalidDomain},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vp := &models.VerifiablePresentation{}
+			token := createJWTPresentation(t, holderKey, credential, descriptor, map[string]interface{}{"aud": test.aud})
+			assert.NoError(t, json.Unmarshal([]byte(`"`+token+`"`), vp))
+
+			_, err := validator.ValidatePresentationResponse(nil, &presentationDefinition, vp, "")
+			assert.Equal(t, test.err, err)
+		})
+	}
+}
+
+func TestDIFValidatorService_ValidateJWTPresentationValidity(t *testing.T) {
+	issuerPub, issuerKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	holderKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	validator := createJWTValidator(t, issuerPub, &holderKey.PublicKey)
+	var presentationDefinition models.PresentationDefinition
+	assert.NoError(t, json.Unmarshal([]byte(jwtPresentationDefinition), &presentationDefinition))
+	credential := createJWTCredential(t, issuerKey, time.Now().Add(time.Hour))
+	descriptor := map[string]interface{}{"id": "email_input", "format": "jwt_vc", "path": "$.vp.verifiableCredential[0]"}
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		err    error
+	}{
+		{"Valid", map[string]interface{}{"nbf": time.Now().Add(-time.Minute).Unix(), "exp": time.Now().Add(time.Minute).Unix()}, nil},
+		{"Expired", map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}, models.ErrPresentationExpired},
+		{"Expired within skew", map[string]interface{}{"exp": time.Now().Add(-time.Second).Unix()}, nil},
+		{"Not valid yet", map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()}, models.ErrPresentationNotYetValid},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vp := &models.VerifiablePresentation{}
+			token := createJWTPresentation(t, holderKey, credential, descriptor, test.claims)
+			assert.NoError(t, json.Unmarshal([]byte(`"`+token+`"`), vp))
+
+			_, err := validator.ValidatePresentationResponse(nil, &presentationDefinition, vp, "")
+			assert.Equal(t, test.err, err)
+		})
+	}
+}
@@ -0,0 +1,374 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/gataca-io/vui-core/log"
+	"github.com/gataca-io/vui-core/models"
+	"github.com/gataca-io/vui-core/tools"
+	"github.com/labstack/echo/v4"
+)
+
+// matchChecks are the checks a holder can perform on its credentials before presenting them, in order, followed by
+// the fields and the disclosure required by the descriptor. Proofs, status and holder binding are left to the verifier.
+var matchChecks = []string{CheckSchema, CheckFormat, CheckValidity, CheckExpiry, CheckAssurance}
+
+// maxSelections bounds the combinations of descriptors and credentials tried to satisfy a definition
+const maxSelections = 1024
+
+// NewDIFMatcher creates a matcher of credentials against DIF presentation definitions, applying the same checks
+// of the DIF validator that don't depend on the proofs of the presentation
+func NewDIFMatcher(opts ...DIFValidatorOption) Matcher {
+	return NewDIFValidatorService(nil, opts...).(*ValidatorServiceDIF)
+}
+
+// MatchCredentials finds which candidate credentials satisfy each input descriptor and the submission requirements
+// of the definition. When the definition is satisfied it selects the credentials to present, one per descriptor,
+// and builds their submission, trying other descriptors and candidates when the first ones don't keep together
+// the subjects the definition asks to be the same. Otherwise, the result explains what is missing along with ErrMissingRequirement.
+func (vs *ValidatorServiceDIF) MatchCredentials(ctx echo.Context, pd *models.PresentationDefinition, candidates []models.VerifiableCredential) (*models.MatchResult, error) {
+	if pd == nil {
+		log.CError(ctx, "Missing presentation definition to match credentials")
+		return nil, models.ErrBadParamInput
+	}
+	compileDefinition(ctx, pd)
+	vs = vs.forVersion(pd.Version())
+	match := &models.MatchResult{Descriptors: []models.DescriptorMatch{}}
+	matched := map[string][]int{}
+	for i := range pd.InputDescriptors {
+		descriptorMatch := vs.matchDescriptor(ctx, pd, &pd.InputDescriptors[i], candidates)
+		match.Descriptors = append(match.Descriptors, descriptorMatch)
+		if descriptorMatch.IsMatched() {
+			matched[descriptorMatch.ID] = descriptorMatch.Credentials
+		}
+	}
+
+	options := [][][]string{}
+	if len(pd.SubmissionRequirements) == 0 {
+		//Without requirements every descriptor must be satisfied
+		selected := []string{}
+		for _, descriptor := range pd.InputDescriptors {
+			if _, ok := matched[descriptor.ID]; !ok {
+				match.Errors = append(match.Errors, fmt.Sprintf("No credential satisfies descriptor %s", descriptor.ID))
+				continue
+			}
+			selected = append(selected, descriptor.ID)
+		}
+		options = append(options, [][]string{selected})
+	}
+	for i := range pd.SubmissionRequirements {
+		requirementMatch, requirementOptions := vs.matchRequirement(ctx, &pd.SubmissionRequirements[i], pd, matched)
+		match.Requirements = append(match.Requirements, requirementMatch)
+		match.Errors = append(match.Errors, requirementMatch.Errors...)
+		options = append(options, requirementOptions)
+	}
+
+	if len(match.Errors) == 0 {
+		vs.selectCredentials(ctx, match, pd, options, matched, candidates)
+	}
+	if len(match.Errors) > 0 {
+		log.CWarnf(ctx, "Credentials don't satisfy definition %s: %v", pd.ID, match.Errors)
+		match.Submission = nil
+		match.VerifiableCredential = nil
+		return match, models.ErrMissingRequirement
+	}
+	match.Satisfied = true
+	return match, nil
+}
+
+// matchDescriptor checks every candidate against an input descriptor, keeping the report of each one
+func (vs *ValidatorServiceDIF) matchDescriptor(ctx echo.Context, pd *models.PresentationDefinition, descriptor *models.InputDescriptor, candidates []models.VerifiableCredential) models.DescriptorMatch {
+	descriptorMatch := models.DescriptorMatch{ID: descriptor.ID, Credentials: []int{}}
+	if descriptor.GetMechanism() != "" {
+		//Mechanisms like second factors are proved by the keys signing the presentation, not by credentials
+		return descriptorMatch
+	}
+	format := descriptor.Format
+	if format == nil {
+		format = pd.Format
+	}
+	for i := range candidates {
+		vc := &candidates[i]
+		result := &models.VerificationResult{}
+		report := models.NewDescriptorReport(descriptor.ID, vc.Id, "")
+		if vs.matchCredential(ctx, result, report, vc, descriptor, format) == nil {
+			descriptorMatch.Credentials = append(descriptorMatch.Credentials, i)
+		}
+		descriptorMatch.Reports = append(descriptorMatch.Reports, *report)
+	}
+	return descriptorMatch
+}
+
+func (vs *ValidatorServiceDIF) matchCredential(ctx echo.Context, result *models.VerificationResult, report *models.DescriptorReport, vc *models.VerifiableCredential, descriptor *models.InputDescriptor, format *models.Format) error {
+	steps := vs.credentialSteps(ctx, result, report, vc, descriptor, format, nil, "")
+	for i, check := range matchChecks {
+		err := runCheck(result, report, check, steps[check].path, steps[check].run)
+		if err != nil {
+			report.Skip(matchChecks[i+1:]...)
+			return err
+		}
+	}
+	constraints := descriptor.Constraints
+	if constraints == nil {
+		return nil
+	}
+	err := vs.validateFieldConstraint(ctx, result, report, vc, constraints.Fields)
+	if err != nil {
+		return err
+	}
+	if constraints.LimitDisclosure.IsLimited() && constraints.LimitDisclosure.Preference == models.Required {
+		return runCheck(result, report, CheckDisclosure, "$.credentialSubject", func() error {
+			return vs.validateLimitDisclosure(ctx, vc, constraints.Fields)
+		})
+	}
+	return nil
+}
+
+// matchRequirement evaluates a submission requirement over the satisfied descriptors, returning the ways it can be
+// satisfied: all of its descriptors, or every combination of as many as its pick rule allows. The first one is
+// reported as the descriptors selected. Nested requirements are evaluated recursively.
+func (vs *ValidatorServiceDIF) matchRequirement(ctx echo.Context, req *models.SubmissionRequirement, pd *models.PresentationDefinition, matched map[string][]int) (models.RequirementMatch, [][]string) {
+	requirementMatch := models.RequirementMatch{Name: req.Name}
+	result := &models.VerificationResult{}
+	options := [][]string{}
+	var err error
+	if len(req.FromNested) > 0 {
+		branchOptions := [][][]string{}
+		for i := range req.FromNested {
+			branch, branchOption := vs.matchRequirement(ctx, &req.FromNested[i], pd, matched)
+			if branch.Satisfied {
+				branchOptions = append(branchOptions, branchOption)
+			}
+		}
+		chosen := pickCount(req, len(branchOptions))
+		for _, combination := range combinations(len(branchOptions), chosen) {
+			selections := [][]string{{}}
+			for _, branch := range combination {
+				selections = crossSelections(selections, branchOptions[branch])
+			}
+			options = append(options, selections...)
+		}
+		err = applyRequirementRule(ctx, result, req, chosen, len(req.FromNested))
+	} else {
+		ids := filterInputDescriptorsIdsInGroup(pd.InputDescriptors, req.From)
+		available := []string{}
+		signed := 0
+		for _, id := range ids {
+			if descriptor := findInputDescriptorWithId(pd.InputDescriptors, id); descriptor.GetMechanism() != "" {
+				//The holder satisfies them by signing the presentation with the keys required
+				signed++
+				continue
+			}
+			if _, ok := matched[id]; ok {
+				available = append(available, id)
+			}
+		}
+		picked := pickCount(req, signed+len(available)) - signed
+		if picked < 0 {
+			picked = 0
+		}
+		for _, combination := range combinations(len(available), picked) {
+			option := []string{}
+			for _, i := range combination {
+				option = append(option, available[i])
+			}
+			options = append(options, option)
+		}
+		err = applyRequirementRule(ctx, result, req, signed+picked, len(ids))
+	}
+	if err != nil || len(options) == 0 {
+		requirementMatch.Errors = result.Errors
+		return requirementMatch, nil
+	}
+	if len(options) > maxSelections {
+		options = options[:maxSelections]
+	}
+	requirementMatch.Descriptors = options[0]
+	requirementMatch.Satisfied = true
+	return requirementMatch, options
+}
+
+// pickCount returns how many of the available elements a requirement selects: all of them,
+// unless a pick rule limits them with its count or maximum
+func pickCount(req *models.SubmissionRequirement, available int) int {
+	if req.Rule != models.Pick {
+		return available
+	}
+	limit := available
+	if req.Count != nil && *req.Count < limit {
+		limit = *req.Count
+	}
+	if req.Maximum != nil && *req.Maximum < limit {
+		limit = *req.Maximum
+	}
+	return limit
+}
+
+// selectCredentials looks for the descriptors of each requirement and the candidates satisfying them that keep
+// together the subjects the definition requires to be the same, trying them in order, and builds the submission
+// referencing them. Credentials satisfying several descriptors are presented once.
+func (vs *ValidatorServiceDIF) selectCredentials(ctx echo.Context, match *models.MatchResult, pd *models.PresentationDefinition, options [][][]string, matched map[string][]int, candidates []models.VerifiableCredential) {
+	directives := []models.SubjectDirective{}
+	for _, directive := range subjectDirectives(pd) {
+		if directive.Directive == models.Required {
+			directives = append(directives, directive)
+		}
+	}
+	chosen := make([]int, len(options))
+	attempts := 0
+	for {
+		selected := []string{}
+		for i, option := range chosen {
+			selected = append(selected, options[i][option]...)
+		}
+		descriptors := []*models.InputDescriptor{}
+		for i := range pd.InputDescriptors {
+			if tools.Contains(selected, pd.InputDescriptors[i].ID) {
+				descriptors = append(descriptors, &pd.InputDescriptors[i])
+			}
+		}
+		assignment := make([]int, len(descriptors))
+		if assignCredentials(directives, descriptors, assignment, 0, matched, candidates, &attempts) {
+			for i := range match.Requirements {
+				match.Requirements[i].Descriptors = options[i][chosen[i]]
+			}
+			vs.buildSubmission(ctx, match, pd, descriptors, assignment, candidates)
+			return
+		}
+		if attempts >= maxSelections || !nextChoice(chosen, options) {
+			break
+		}
+	}
+	log.CWarnf(ctx, "No selection of credentials keeps together the subjects required by definition %s after %d attempts", pd.ID, attempts)
+	match.Errors = append(match.Errors, "No selection of credentials keeps together the subjects the definition requires to be the same")
+}
+
+// assignCredentials backtracks over the candidates of the descriptors from next on, keeping the ones whose subjects
+// hold the same_subject directives along with the candidates already assigned. It gives up after maxSelections attempts.
+func assignCredentials(directives []models.SubjectDirective, descriptors []*models.InputDescriptor, assignment []int, next int, matched map[string][]int, candidates []models.VerifiableCredential, attempts *int) bool {
+	if next == len(descriptors) {
+		return true
+	}
+	for _, candidate := range matched[descriptors[next].ID] {
+		if *attempts >= maxSelections {
+			return false
+		}
+		*attempts++
+		assignment[next] = candidate
+		if sameSubjectHeld(directives, descriptors[:next+1], assignment[:next+1], candidates) &&
+			assignCredentials(directives, descriptors, assignment, next+1, matched, candidates, attempts) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameSubjectHeld tells if the credentials assigned to the descriptors are about the same subject
+// whenever a directive relates their fields
+func sameSubjectHeld(directives []models.SubjectDirective, descriptors []*models.InputDescriptor, assignment []int, candidates []models.VerifiableCredential) bool {
+	for _, directive := range directives {
+		subjects := map[string]bool{}
+		for i, descriptor := range descriptors {
+			if descriptor.Constraints == nil || !descriptor.Constraints.HasField(directive.FieldId) {
+				continue
+			}
+			if subject := candidates[assignment[i]].CredentialSubject; subject != nil {
+				id, _ := (*subject)["id"].(string)
+				subjects[id] = true
+			}
+		}
+		if len(subjects) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// buildSubmission references the credentials assigned to the descriptors in a new submission
+func (vs *ValidatorServiceDIF) buildSubmission(ctx echo.Context, match *models.MatchResult, pd *models.PresentationDefinition, descriptors []*models.InputDescriptor, assignment []int, candidates []models.VerifiableCredential) {
+	builder := models.NewPresentationSubmissionBuilder(pd.ID)
+	positions := map[int]int{}
+	for i, descriptor := range descriptors {
+		candidate := assignment[i]
+		position, ok := positions[candidate]
+		if !ok {
+			position = len(match.VerifiableCredential)
+			positions[candidate] = position
+			match.VerifiableCredential = append(match.VerifiableCredential, candidates[candidate])
+		}
+		err := builder.AddDescriptor(models.Descriptor{
+			ID:     descriptor.ID,
+			Path:   fmt.Sprintf("$.verifiableCredential[%d]", position),
+			Format: submissionFormat(&candidates[candidate]),
+		})
+		if err != nil {
+			log.CErrorf(ctx, "Cannot submit credential %s for descriptor %s: %v", candidates[candidate].Id, descriptor.ID, err)
+			match.Errors = append(match.Errors, fmt.Sprintf("Cannot submit credential for descriptor %s", descriptor.ID))
+			return
+		}
+	}
+	holder, err := builder.Build()
+	if err != nil {
+		log.CErrorf(ctx, "Cannot build the submission for definition %s: %v", pd.ID, err)
+		match.Errors = append(match.Errors, "No credentials to submit")
+		return
+	}
+	match.Submission = &holder.PresentationSubmission
+}
+
+// combinations returns the ways of choosing k of n elements, in order, up to maxSelections of them
+func combinations(n, k int) [][]int {
+	found := [][]int{}
+	combination := make([]int, 0, k)
+	var choose func(from int)
+	choose = func(from int) {
+		if len(found) >= maxSelections {
+			return
+		}
+		if len(combination) == k {
+			found = append(found, append([]int{}, combination...))
+			return
+		}
+		for i := from; i <= n-(k-len(combination)); i++ {
+			combination = append(combination, i)
+			choose(i + 1)
+			combination = combination[:len(combination)-1]
+		}
+	}
+	choose(0)
+	return found
+}
+
+// crossSelections joins every selection with every option, up to maxSelections of them
+func crossSelections(selections [][]string, options [][]string) [][]string {
+	crossed := [][]string{}
+	for _, selection := range selections {
+		for _, option := range options {
+			if len(crossed) >= maxSelections {
+				return crossed
+			}
+			crossed = append(crossed, append(append([]string{}, selection...), option...))
+		}
+	}
+	return crossed
+}
+
+// nextChoice moves to the next combination of the options of each requirement, telling if there is any left
+func nextChoice(chosen []int, options [][][]string) bool {
+	for i := len(chosen) - 1; i >= 0; i-- {
+		if chosen[i]+1 < len(options[i]) {
+			chosen[i]++
+			return true
+		}
+		chosen[i] = 0
+	}
+	return false
+}
+
+// submissionFormat returns the format a credential is submitted with, according to its proof
+func submissionFormat(vc *models.VerifiableCredential) models.CredentialFormat {
+	if findJWTProof(vc.GetProofs()) != nil {
+		return models.CredentialFormat(models.JWTVC)
+	}
+	return models.CredentialFormat(models.LDPVC)
+}
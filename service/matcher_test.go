@@ -0,0 +1,100 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gataca-io/vui-core/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDIFMatcher_MatchCredentials(t *testing.T) {
+	presentationDefinition := createPresentationDefinition(t)
+	candidates := createVerifiablePresentation(t).VerifiableCredential
+
+	match, err := difValidator.MatchCredentials(nil, presentationDefinition, candidates)
+	assert.NoError(t, err)
+	assert.True(t, match.Satisfied)
+	assert.Empty(t, match.Errors)
+	assert.Equal(t, len(presentationDefinition.InputDescriptors), len(match.Descriptors))
+	assert.Equal(t, len(presentationDefinition.SubmissionRequirements), len(match.Requirements))
+	for _, requirement := range match.Requirements {
+		assert.True(t, requirement.Satisfied)
+	}
+	assert.Equal(t, len(match.Submission.DescriptorMap), len(match.VerifiableCredential))
+	assert.Equal(t, presentationDefinition.ID, match.Submission.DefinitionID)
+
+	//The validator accepts the proposed submission once the holder proves it
+	vp := match.Presentation()
+	vp.Proof = createVerifiablePresentation(t).Proof
+	res, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.NoError(t, err)
+	assert.Empty(t, res.Errors)
+}
+
+func TestDIFMatcher_MatchCredentialsMissing(t *testing.T) {
+	presentationDefinition := createPresentationDefinition(t)
+	candidates := createVerifiablePresentation(t).VerifiableCredential
+	candidates[1].ExpirationDate = &models.TimeWithFormat{Time: time.Now().Add(-time.Hour)} //Employment credential no longer satisfies its descriptor
+
+	match, err := difValidator.MatchCredentials(nil, presentationDefinition, candidates)
+	assert.Equal(t, models.ErrMissingRequirement, err)
+	assert.False(t, match.Satisfied)
+	assert.NotEmpty(t, match.Errors)
+	assert.Nil(t, match.Submission)
+	assert.Nil(t, match.Presentation())
+	for _, descriptor := range match.Descriptors {
+		if descriptor.ID == "employment_input" {
+			assert.False(t, descriptor.IsMatched())
+			assert.Equal(t, len(candidates), len(descriptor.Reports))
+		}
+	}
+}
+
+func TestDIFMatcher_PickCount(t *testing.T) {
+	one, two := 1, 2
+	assert.Equal(t, 3, pickCount(&models.SubmissionRequirement{Rule: models.All}, 3))
+	assert.Equal(t, 1, pickCount(&models.SubmissionRequirement{Rule: models.Pick, Count: &one}, 3))
+	assert.Equal(t, 2, pickCount(&models.SubmissionRequirement{Rule: models.Pick, Maximum: &two}, 3))
+	assert.Equal(t, 1, pickCount(&models.SubmissionRequirement{Rule: models.Pick, Maximum: &two}, 1))
+}
+
+func TestDIFMatcher_MatchCredentialsSameSubject(t *testing.T) {
+	presentationDefinition := createV2PresentationDefinition(t, models.Required)
+	sample := createVerifiablePresentation(t).VerifiableCredential
+	other := createVerifiablePresentation(t).VerifiableCredential[1]
+	other.Id = "https://example.com/credentials/other"
+	(*other.CredentialSubject)["id"] = "did:example:212444"
+
+	//The first employment candidate is about another subject, so the matcher tries the next one
+	candidates := append([]models.VerifiableCredential{other}, sample...)
+	match, err := difValidator.MatchCredentials(nil, presentationDefinition, candidates)
+	assert.NoError(t, err)
+	assert.True(t, match.Satisfied)
+	for _, descriptor := range match.Descriptors {
+		if descriptor.ID == "employment_input" {
+			assert.Equal(t, []int{0, 2}, descriptor.Credentials)
+		}
+	}
+	for _, vc := range match.VerifiableCredential {
+		assert.NotEqual(t, other.Id, vc.Id)
+	}
+	vp := match.Presentation()
+	res := createEmptyVerificationResult()
+	assert.NoError(t, difValidator.validateSameSubject(nil, res, presentationDefinition, vp, ""))
+	assert.Contains(t, res.Checks, CheckSameSubject)
+
+	//Without a candidate about the same subject the definition is not satisfied
+	candidates = []models.VerifiableCredential{other, sample[0], sample[2]}
+	match, err = difValidator.MatchCredentials(nil, presentationDefinition, candidates)
+	assert.Equal(t, models.ErrMissingRequirement, err)
+	assert.False(t, match.Satisfied)
+	assert.NotEmpty(t, match.Errors)
+}
+
+func TestDIFMatcher_Combinations(t *testing.T) {
+	assert.Equal(t, [][]int{{0, 1}, {0, 2}, {1, 2}}, combinations(3, 2))
+	assert.Equal(t, [][]int{{}}, combinations(2, 0))
+	assert.Empty(t, combinations(1, 2))
+	assert.Equal(t, [][]string{{"a", "c"}, {"b", "c"}}, crossSelections([][]string{{"a"}, {"b"}}, [][]string{{"c"}}))
+}
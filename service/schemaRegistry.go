@@ -0,0 +1,272 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gataca-io/vui-core/log"
+	"github.com/gataca-io/vui-core/models"
+)
+
+const (
+	defaultSchemaTTL     = time.Hour
+	defaultCachedSchemas = 256
+	thresholdSchemaFetch = 5 * time.Second
+	maxSchemaSize        = 1 << 20
+)
+
+// SchemaRegistryOption configures the schemas known by a registry
+type SchemaRegistryOption func(sr *schemaRegistry) error
+
+type cachedSchema struct {
+	schema  []byte
+	expires time.Time
+}
+
+// schemaFetch is a fetch in progress, shared by every lookup of the schema until it is done
+type schemaFetch struct {
+	done   chan struct{}
+	schema []byte
+	err    error
+}
+
+// schemaRegistry resolves schemas from the ones preloaded, so verifications don't depend on the hosts publishing them.
+// Schemas not preloaded are only fetched when fetching is enabled, and kept for a limited time. If their host is down,
+// the copy kept is used even if expired.
+type schemaRegistry struct {
+	preloaded map[string][]byte
+	fetching  bool
+	ttl       time.Duration
+	maxCached int
+	client    *http.Client
+	clock     func() time.Time
+
+	mu       sync.Mutex
+	cached   map[string]cachedSchema
+	inflight map[string]*schemaFetch
+}
+
+// NewSchemaRegistry creates a registry with the schemas given by the options. Without WithSchemaFetch, schemas
+// not preloaded are not available, so verifications are deterministic and work offline.
+func NewSchemaRegistry(opts ...SchemaRegistryOption) (SchemaRegistry, error) {
+	sr := newSchemaRegistry()
+	for _, opt := range opts {
+		if err := opt(sr); err != nil {
+			return nil, err
+		}
+	}
+	return sr, nil
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		preloaded: map[string][]byte{},
+		ttl:       defaultSchemaTTL,
+		maxCached: defaultCachedSchemas,
+		client:    &http.Client{Timeout: thresholdSchemaFetch},
+		cached:    map[string]cachedSchema{},
+		inflight:  map[string]*schemaFetch{},
+	}
+}
+
+// newFetchingSchemaRegistry creates the registry used by default, fetching every schema from its host and caching it
+func newFetchingSchemaRegistry() SchemaRegistry {
+	sr := newSchemaRegistry()
+	sr.fetching = true
+	return sr
+}
+
+// WithSchemas preloads schemas by their URI, like the schemas embedded in the binary
+func WithSchemas(schemas map[string][]byte) SchemaRegistryOption {
+	return func(sr *schemaRegistry) error {
+		for uri, schema := range schemas {
+			if err := sr.preload(uri, schema); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithSchemaDirectory preloads the JSON files of a directory, each one by the URI of its $id
+func WithSchemaDirectory(dir string) SchemaRegistryOption {
+	return func(sr *schemaRegistry) error {
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			schema, err := ioutil.ReadFile(file)
+			if err != nil {
+				log.Errorf("Cannot read schema %s: %v", file, err)
+				return err
+			}
+			var identified struct {
+				Id string `json:"$id"`
+			}
+			if json.Unmarshal(schema, &identified) != nil || identified.Id == "" {
+				log.Warnf("Schema %s has no $id, it can only be loaded with a mapping", file)
+				continue
+			}
+			if err = sr.preload(identified.Id, schema); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithSchemaMapping preloads the schemas published at the given URIs from their local copies
+func WithSchemaMapping(mapping map[string]string) SchemaRegistryOption {
+	return func(sr *schemaRegistry) error {
+		for uri, file := range mapping {
+			schema, err := ioutil.ReadFile(file)
+			if err != nil {
+				log.Errorf("Cannot read the local copy %s of schema %s: %v", file, uri, err)
+				return err
+			}
+			if err = sr.preload(uri, schema); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithSchemaFetch fetches the schemas not preloaded from their URI, keeping up to maxSchemas of them for the given time
+func WithSchemaFetch(ttl time.Duration, maxSchemas int) SchemaRegistryOption {
+	return func(sr *schemaRegistry) error {
+		if ttl <= 0 || maxSchemas <= 0 {
+			return models.ErrBadParamInput
+		}
+		sr.fetching = true
+		sr.ttl = ttl
+		sr.maxCached = maxSchemas
+		return nil
+	}
+}
+
+func (sr *schemaRegistry) preload(uri string, schema []byte) error {
+	if !isJSON(string(schema)) {
+		log.Errorf("Schema %s is not valid json", uri)
+		return models.ErrInvalidFormat
+	}
+	sr.preloaded[schemaKey(uri)] = schema
+	return nil
+}
+
+// GetSchema returns the schema preloaded for the URI or, if fetching is enabled, the one published at it
+func (sr *schemaRegistry) GetSchema(uri string) ([]byte, error) {
+	key := schemaKey(uri)
+	if schema, ok := sr.preloaded[key]; ok {
+		return schema, nil
+	}
+	if !sr.fetching {
+		log.Errorf("Schema %s is not available offline", uri)
+		return nil, models.ErrSchemaNotAvailable
+	}
+	now := sr.now()
+	sr.mu.Lock()
+	cached, ok := sr.cached[key]
+	sr.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.schema, nil
+	}
+	schema, err := sr.fetchOnce(key, uri, now)
+	if err != nil {
+		if ok {
+			log.Warnf("Using the expired copy of schema %s: %v", uri, err)
+			return cached.schema, nil
+		}
+		return nil, models.ErrSchemaNotAvailable
+	}
+	return schema, nil
+}
+
+// fetchOnce fetches a schema and stores it, or waits for the fetch already in progress for it, so concurrent
+// validations missing the same schema request it only once
+func (sr *schemaRegistry) fetchOnce(key, uri string, now time.Time) ([]byte, error) {
+	sr.mu.Lock()
+	if fetch, ok := sr.inflight[key]; ok {
+		sr.mu.Unlock()
+		<-fetch.done
+		return fetch.schema, fetch.err
+	}
+	fetch := &schemaFetch{done: make(chan struct{})}
+	sr.inflight[key] = fetch
+	sr.mu.Unlock()
+
+	fetch.schema, fetch.err = sr.fetch(uri)
+	if fetch.err == nil {
+		sr.store(key, fetch.schema, now)
+	}
+	sr.mu.Lock()
+	delete(sr.inflight, key)
+	sr.mu.Unlock()
+	close(fetch.done)
+	return fetch.schema, fetch.err
+}
+
+func (sr *schemaRegistry) fetch(uri string) ([]byte, error) {
+	res, err := sr.client.Get(uri)
+	if err != nil {
+		log.Errorf("Error requesting schema %s. Error: %v", uri, err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		log.Errorf("Error requesting schema %s. Status code not success: %d", uri, res.StatusCode)
+		return nil, models.ErrSchemaNotAvailable
+	}
+	schema, err := ioutil.ReadAll(io.LimitReader(res.Body, maxSchemaSize))
+	if err != nil {
+		log.Errorf("Error reading schema %s. Error: %v", uri, err)
+		return nil, err
+	}
+	if !isJSON(string(schema)) {
+		log.Errorf("Schema %s is not valid json", uri)
+		return nil, models.ErrInvalidFormat
+	}
+	return schema, nil
+}
+
+// store keeps a fetched schema, evicting the expired ones or, if none, the closest to expire when the cache is full
+func (sr *schemaRegistry) store(key string, schema []byte, now time.Time) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if _, ok := sr.cached[key]; !ok && len(sr.cached) >= sr.maxCached {
+		for k, c := range sr.cached {
+			if !now.Before(c.expires) {
+				delete(sr.cached, k)
+			}
+		}
+		for len(sr.cached) >= sr.maxCached {
+			oldest := ""
+			for k, c := range sr.cached {
+				if oldest == "" || c.expires.Before(sr.cached[oldest].expires) {
+					oldest = k
+				}
+			}
+			delete(sr.cached, oldest)
+		}
+	}
+	sr.cached[key] = cachedSchema{schema: schema, expires: now.Add(sr.ttl)}
+}
+
+func (sr *schemaRegistry) now() time.Time {
+	if sr.clock == nil {
+		return time.Now()
+	}
+	return sr.clock()
+}
+
+// schemaKey identifies a schema by its URI, ignoring the empty fragment some documents reference it with
+func schemaKey(uri string) string {
+	return strings.TrimSuffix(strings.TrimSpace(uri), "#")
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gataca-io/vui-core/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	emailSchemaURI = "https://schemas.example.com/email.json"
+	emailSchema    = `{
+		"$id": "https://schemas.example.com/email.json",
+		"type": "object",
+		"required": ["credentialSubject"],
+		"properties": {
+			"credentialSubject": {
+				"type": "object",
+				"required": ["email"],
+				"properties": {"email": {"type": "string", "format": "email"}}
+			}
+		}
+	}`
+)
+
+const (
+	contactSchemaURI = "https://schemas.example.com/contact.json"
+	contactSchema    = `{
+		"$id": "https://schemas.example.com/contact.json",
+		"type": "object",
+		"required": ["credentialSubject"],
+		"properties": {
+			"credentialSubject": {"$ref": "https://schemas.example.com/email.json#/properties/credentialSubject"}
+		}
+	}`
+)
+
+func newSchemaServer(t *testing.T, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if r.URL.Path != "/email.json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, err := w.Write([]byte(emailSchema))
+		assert.NoError(t, err)
+	}))
+}
+
+func emailCredential(schema, email string) *models.VerifiableCredential {
+	return &models.VerifiableCredential{
+		Id:                "cred:example:email",
+		CredentialSchema:  &models.CredentialSchema{Id: schema, Type: "JsonSchemaValidator2018"},
+		CredentialSubject: &map[string]interface{}{"email": email},
+	}
+}
+
+func TestSchemaRegistry_Preloaded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schemas")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "email.json"), []byte(emailSchema), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "anonymous.json"), []byte(`{"type": "object"}`), 0600))
+
+	tests := []struct {
+		name   string
+		option SchemaRegistryOption
+	}{
+		{"Directory", WithSchemaDirectory(dir)},
+		{"Mapping", WithSchemaMapping(map[string]string{emailSchemaURI: filepath.Join(dir, "email.json")})},
+		{"Embedded", WithSchemas(map[string][]byte{emailSchemaURI: []byte(emailSchema)})},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			registry, err := NewSchemaRegistry(test.option)
+			assert.NoError(t, err)
+			validator := NewJSONValidator(registry)
+			assert.NoError(t, validator.Validate(emailCredential(emailSchemaURI, "holder@example.com")))
+			assert.NoError(t, validator.Validate(emailCredential(emailSchemaURI+"#", "holder@example.com")))
+			assert.Equal(t, models.ErrInvalidFormat, validator.Validate(emailCredential(emailSchemaURI, "not an email")))
+
+			//Schemas not preloaded are not fetched
+			assert.Equal(t, models.ErrSchemaNotAvailable, validator.Validate(emailCredential("https://schemas.example.com/phone.json", "holder@example.com")))
+		})
+	}
+
+	_, err = NewSchemaRegistry(WithSchemaMapping(map[string]string{emailSchemaURI: filepath.Join(dir, "missing.json")}))
+	assert.Error(t, err)
+	_, err = NewSchemaRegistry(WithSchemas(map[string][]byte{emailSchemaURI: []byte("not json")}))
+	assert.Equal(t, models.ErrInvalidFormat, err)
+}
+
+func TestSchemaRegistry_Fetch(t *testing.T) {
+	requests := 0
+	server := newSchemaServer(t, &requests)
+	now := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	registry, err := NewSchemaRegistry(WithSchemaFetch(time.Hour, 2))
+	assert.NoError(t, err)
+	registry.(*schemaRegistry).clock = func() time.Time { return now }
+	validator := NewJSONValidator(registry)
+	uri := server.URL + "/email.json"
+
+	assert.NoError(t, validator.Validate(emailCredential(uri, "holder@example.com")))
+	assert.NoError(t, validator.Validate(emailCredential(uri, "holder@example.com")))
+	assert.Equal(t, 1, requests)
+
+	//Expired schemas are fetched again
+	now = now.Add(2 * time.Hour)
+	assert.NoError(t, validator.Validate(emailCredential(uri, "holder@example.com")))
+	assert.Equal(t, 2, requests)
+
+	//The copy kept is used while the host is down
+	now = now.Add(2 * time.Hour)
+	server.Close()
+	assert.NoError(t, validator.Validate(emailCredential(uri, "holder@example.com")))
+	assert.Equal(t, models.ErrSchemaNotAvailable, validator.Validate(emailCredential(server.URL+"/phone.json", "holder@example.com")))
+
+	_, err = NewSchemaRegistry(WithSchemaFetch(0, 2))
+	assert.Equal(t, models.ErrBadParamInput, err)
+}
+
+func TestSchemaRegistry_CacheLimit(t *testing.T) {
+	now := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	registry := newSchemaRegistry()
+	registry.maxCached = 2
+	registry.clock = func() time.Time { return now }
+	registry.store("a", []byte("{}"), now)
+	registry.store("b", []byte("{}"), now.Add(time.Minute))
+	registry.store("c", []byte("{}"), now.Add(2*time.Minute))
+	assert.Len(t, registry.cached, 2)
+	assert.NotContains(t, registry.cached, "a")
+
+	//Expired schemas are evicted first
+	registry.store("d", []byte("{}"), now.Add(-2*time.Hour))
+	registry.store("e", []byte("{}"), now.Add(3*time.Minute))
+	assert.Len(t, registry.cached, 2)
+	assert.Contains(t, registry.cached, "c")
+	assert.Contains(t, registry.cached, "e")
+}
+
+func TestSchemaRegistry_References(t *testing.T) {
+	requests := 0
+	server := newSchemaServer(t, &requests)
+	defer server.Close()
+
+	registry, err := NewSchemaRegistry(WithSchemas(map[string][]byte{
+		contactSchemaURI: []byte(contactSchema),
+		emailSchemaURI:   []byte(emailSchema),
+	}))
+	assert.NoError(t, err)
+	validator := NewJSONValidator(registry)
+	assert.NoError(t, validator.Validate(emailCredential(contactSchemaURI, "holder@example.com")))
+	assert.Equal(t, models.ErrInvalidFormat, validator.Validate(emailCredential(contactSchemaURI, "not an email")))
+
+	//References are resolved by the registry, so they are not fetched when they aren't preloaded
+	remote := strings.Replace(contactSchema, emailSchemaURI, server.URL+"/email.json", 1)
+	registry, err = NewSchemaRegistry(WithSchemas(map[string][]byte{contactSchemaURI: []byte(remote)}))
+	assert.NoError(t, err)
+	validator = NewJSONValidator(registry)
+	assert.Equal(t, models.ErrSchemaNotAvailable, validator.Validate(emailCredential(contactSchemaURI, "holder@example.com")))
+	assert.Equal(t, 0, requests)
+
+	//Unless the registry fetches them
+	registry, err = NewSchemaRegistry(WithSchemas(map[string][]byte{contactSchemaURI: []byte(remote)}), WithSchemaFetch(time.Hour, 2))
+	assert.NoError(t, err)
+	validator = NewJSONValidator(registry)
+	assert.NoError(t, validator.Validate(emailCredential(contactSchemaURI, "holder@example.com")))
+	assert.Equal(t, 1, requests)
+}
+
+func TestSchemaRegistry_ConcurrentFetch(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(50 * time.Millisecond)
+		_, err := w.Write([]byte(emailSchema))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+	registry, err := NewSchemaRegistry(WithSchemaFetch(time.Hour, 2))
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			schema, err := registry.GetSchema(server.URL + "/email.json")
+			assert.NoError(t, err)
+			assert.Equal(t, emailSchema, string(schema))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
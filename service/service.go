@@ -31,10 +31,26 @@ type JSONValidator interface {
 	ValidateStrings(schema, document string) error
 }
 
+// SchemaRegistry resolves the JSON schemas referenced by credentials, by their URI
+type SchemaRegistry interface {
+	GetSchema(uri string) ([]byte, error)
+}
+
 type Validator interface {
 	ValidatePresentationResponse(ctx echo.Context, pr models.ExchangeRequest, resp models.ExchangeResponse, requesterVMethod string) (*models.VerificationResult, error)
 }
 
+// PolicyValidator is implemented by the validators able to enforce a verification policy on each validation
+type PolicyValidator interface {
+	Validator
+	ValidatePresentationResponseWithPolicy(ctx echo.Context, pr models.ExchangeRequest, resp models.ExchangeResponse, requesterVMethod string, policy *models.VerificationPolicy) (*models.VerificationResult, error)
+}
+
+// Matcher selects, on the holder side, the credentials satisfying a presentation definition
+type Matcher interface {
+	MatchCredentials(ctx echo.Context, pd *models.PresentationDefinition, candidates []models.VerifiableCredential) (*models.MatchResult, error)
+}
+
 type DidService interface {
 	GetDID(ctx echo.Context, did string) (*models.DIDDocument, error)
 	CreateDID(ctx echo.Context, did *models.DIDDocument) error
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gataca-io/vui-core/constant"
+	"github.com/gataca-io/vui-core/log"
+	"github.com/gataca-io/vui-core/models"
+	"github.com/labstack/echo/v4"
+)
+
+// maxStatusListSize bounds the decompressed bitstring of a status list. Lists of the recommended size take 16KB.
+const maxStatusListSize = 16 << 20
+
+// StatusResolver obtains the current status of a credential following the protocol of its credentialStatus type.
+// The status returned is one of the credential status constants, like constant.CredentialStatusIssued.
+type StatusResolver interface {
+	ResolveStatus(ctx echo.Context, cred *models.VerifiableCredential, requesterVMethod string) (string, error)
+}
+
+// WithStatusResolver registers the resolver used for credentials with the given credentialStatus type
+func WithStatusResolver(statusType string, resolver StatusResolver) DIFValidatorOption {
+	return func(vs *ValidatorServiceDIF) {
+		if vs.statusResolvers == nil {
+			vs.statusResolvers = map[string]StatusResolver{}
+		}
+		vs.statusResolvers[statusType] = resolver
+	}
+}
+
+// getStatusResolver returns the resolver registered for a status type, falling back to the built-in ones.
+// Status types without a resolver are not supported, as the status of their credentials can't be known.
+func (vs *ValidatorServiceDIF) getStatusResolver(statusType string) (StatusResolver, error) {
+	if resolver, ok := vs.statusResolvers[statusType]; ok {
+		return resolver, nil
+	}
+	switch statusType {
+	case constant.StatusList2021Entry, constant.RevocationList2020Status:
+		return &bitstringStatusResolver{vs: vs}, nil
+	case constant.CredentialStatusList2017:
+		return &gatacaStatusResolver{}, nil
+	default:
+		return nil, models.ErrUnsupportedStatus
+	}
+}
+
+// gatacaStatusResolver queries the list of statuses published by Gataca issuers, looking up the credential by its id
+type gatacaStatusResolver struct{}
+
+func (gs *gatacaStatusResolver) ResolveStatus(ctx echo.Context, cred *models.VerifiableCredential, requesterVMethod string) (string, error) {
+	vst := models.VerifiableStatus{}
+	err := fetchStatusDocument(ctx, cred.CredentialStatus.Id, &vst)
+	if err != nil {
+		return "", err
+	}
+
+	for _, c := range vst.VerifiableCredential {
+		if c.Claim != nil && c.Claim.Id == cred.Id {
+			log.CDebugf(ctx, "[Validation Status] Status found: %s", c.Claim.CurrentStatus)
+			return c.Claim.CurrentStatus, nil
+		}
+	}
+
+	log.CWarnf(ctx, "[Validation Status] Credential status scanned but not found")
+	return "", models.ErrStatusNotValid
+}
+
+// bitstringStatusResolver checks the position of the credential in the bitstring of a W3C StatusList2021 or
+// RevocationList2020 credential, after verifying the proof of the list credential itself, as JSON or JWT. The list
+// must be issued by the issuer of the credential, as anyone else could publish a list marking it as valid, and must
+// not have expired, as a stale list could hide a revocation.
+type bitstringStatusResolver struct {
+	vs *ValidatorServiceDIF
+}
+
+func (bs *bitstringStatusResolver) ResolveStatus(ctx echo.Context, cred *models.VerifiableCredential, requesterVMethod string) (string, error) {
+	status := cred.CredentialStatus
+	listURI, index, purpose := status.StatusListCredential, status.StatusListIndex, status.StatusPurpose
+	if status.Type == constant.RevocationList2020Status {
+		listURI, index, purpose = status.RevocationListCredential, status.RevocationListIndex, constant.StatusPurposeRevocation
+	}
+	position, err := strconv.Atoi(index)
+	if err != nil || position < 0 || listURI == "" {
+		log.CErrorf(ctx, "Invalid status list entry %s at index %s", listURI, index)
+		return "", models.ErrStatusNotValid
+	}
+
+	listCred, err := fetchStatusCredential(ctx, listURI)
+	if err != nil {
+		return "", err
+	}
+	if listCred.Issuer == "" || issuerDID(listCred.Issuer) != issuerDID(cred.Issuer) {
+		log.CErrorf(ctx, "Status list %s issued by %s, not by the issuer %s of the credential", listURI, listCred.Issuer, cred.Issuer)
+		return "", models.ErrStatusNotValid
+	}
+	err = bs.vs.verifyCredentialProof(ctx, listCred, requesterVMethod)
+	if err != nil {
+		log.CErrorf(ctx, "Status list credential %s couldn't be cryptographically validated", listURI)
+		return "", err
+	}
+	if listCred.ExpirationDate != nil && listCred.ExpirationDate.Before(bs.vs.now().Add(-bs.vs.clockSkew)) {
+		log.CErrorf(ctx, "Status list credential %s expired on %s", listURI, listCred.ExpirationDate.Time.Format(time.RFC3339))
+		return "", models.ErrStatusNotValid
+	}
+	if listCred.CredentialSubject == nil {
+		log.CErrorf(ctx, "Status list credential %s has no subject", listURI)
+		return "", models.ErrStatusNotValid
+	}
+	subject := *listCred.CredentialSubject
+	if listPurpose, ok := subject["statusPurpose"].(string); ok && purpose != "" && listPurpose != purpose {
+		log.CErrorf(ctx, "Status list purpose %s doesn't match the entry purpose %s", listPurpose, purpose)
+		return "", models.ErrStatusNotValid
+	}
+	encodedList, ok := subject["encodedList"].(string)
+	if !ok {
+		log.CErrorf(ctx, "Status list credential %s has no encoded list", listURI)
+		return "", models.ErrStatusNotValid
+	}
+	bitstring, err := decodeStatusList(encodedList)
+	if err != nil {
+		log.CErrorf(ctx, "Cannot decode status list %s: %v", listURI, err)
+		return "", models.ErrStatusNotValid
+	}
+	if position >= len(bitstring)*8 {
+		log.CErrorf(ctx, "Status list index %d out of the %d entries of the list", position, len(bitstring)*8)
+		return "", models.ErrStatusNotValid
+	}
+
+	//The first index is located at the left-most bit of the bitstring
+	if bitstring[position/8]&(0x80>>uint(position%8)) == 0 {
+		return constant.CredentialStatusIssued, nil
+	}
+	if purpose == constant.StatusPurposeSuspension {
+		return constant.CredentialStatusSuspended, nil
+	}
+	return constant.CredentialStatusRevoked, nil
+}
+
+// issuerDID returns the DID of an issuer, without the key fragment some issuers are stated with
+func issuerDID(issuer string) string {
+	return strings.SplitN(issuer, "#", 2)[0]
+}
+
+// decodeStatusList returns the bitstring of a gzip-compressed and base64 encoded status list.
+// Both padded and unpadded base64url encodings are accepted, with or without a multibase prefix.
+// Lists decompressing beyond maxStatusListSize are refused, so a small document can't exhaust the memory.
+func decodeStatusList(encodedList string) ([]byte, error) {
+	encodedList = strings.TrimRight(strings.TrimPrefix(encodedList, "u"), "=")
+	compressed, err := base64.RawURLEncoding.DecodeString(encodedList)
+	if err != nil {
+		compressed, err = base64.RawStdEncoding.DecodeString(encodedList)
+		if err != nil {
+			return nil, err
+		}
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	bitstring, err := ioutil.ReadAll(io.LimitReader(reader, maxStatusListSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(bitstring) > maxStatusListSize {
+		return nil, models.ErrStatusNotValid
+	}
+	return bitstring, nil
+}
+
+// fetchStatusCredential retrieves a status list credential, published either as a JSON credential or as a JWT
+func fetchStatusCredential(ctx echo.Context, uri string) (*models.VerifiableCredential, error) {
+	body, err := fetchStatusBody(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := strings.TrimSpace(string(body)); !strings.HasPrefix(trimmed, "{") {
+		listCred, err := models.ParseJWTCredential(trimmed)
+		if err != nil {
+			log.CErrorf(ctx, "Error decoding status list JWT %s. Error: %v", uri, err)
+			return nil, err
+		}
+		return listCred, nil
+	}
+	listCred := &models.VerifiableCredential{}
+	err = json.Unmarshal(body, listCred)
+	if err != nil {
+		log.CErrorf(ctx, "Error decoding requesting credential status %s. Error: %v", uri, err)
+		return nil, err
+	}
+	return listCred, nil
+}
+
+// fetchStatusDocument retrieves and decodes a JSON status document, bound to the request lifetime and status timeout
+func fetchStatusDocument(ctx echo.Context, uri string, document interface{}) error {
+	body, err := fetchStatusBody(ctx, uri)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(body, document)
+	if err != nil {
+		log.CErrorf(ctx, "Error decoding requesting credential status %s. Error: %v", uri, err)
+		return err
+	}
+	return nil
+}
+
+// fetchStatusBody retrieves the raw body of a status document, bound to the request lifetime and status timeout
+func fetchStatusBody(ctx echo.Context, uri string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(requestContext(ctx), "GET", uri, nil)
+	if err != nil {
+		log.CErrorf(ctx, "Error creating service request to query status %s. Error: %v", uri, err)
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(log.HeaderXSpanId, log.GetTraceId(ctx))
+
+	//Setting timeout as context on the request to prevent slow response on offline/block hosts
+	client := &http.Client{Timeout: thresholdVCStatusCheck}
+
+	res, err := client.Do(req)
+	if err != nil {
+		log.CErrorf(ctx, "Error requesting credential status %s. Error: %v", uri, err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		log.CErrorf(ctx, "Error requesting credential status %s. Status code not success: %d", uri, res.StatusCode)
+		return nil, models.ErrStatusNotValid
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxStatusListSize))
+	if err != nil {
+		log.CErrorf(ctx, "Error reading credential status %s. Error: %v", uri, err)
+		return nil, err
+	}
+	return body, nil
+}
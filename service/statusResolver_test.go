@@ -0,0 +1,262 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gataca-io/vui-core/constant"
+	"github.com/gataca-io/vui-core/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeStatusList(t *testing.T, revoked ...int) string {
+	bitstring := make([]byte, 16*1024)
+	for _, i := range revoked {
+		bitstring[i/8] |= 0x80 >> uint(i%8)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(bitstring)
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes())
+}
+
+func newStatusServer(t *testing.T, documents map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		document, ok := documents[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if jwt, ok := document.(string); ok {
+			_, err := w.Write([]byte(jwt))
+			assert.NoError(t, err)
+			return
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(document))
+	}))
+}
+
+func resolveStatus(t *testing.T, cred *models.VerifiableCredential) (string, error) {
+	resolver, err := difValidator.getStatusResolver(cred.CredentialStatus.Type)
+	assert.NoError(t, err)
+	return resolver.ResolveStatus(nil, cred, "")
+}
+
+func createStatusListCredential(purpose, encodedList string) *models.VerifiableCredential {
+	return &models.VerifiableCredential{
+		Id:     "https://issuer.example.com/status/1",
+		Type:   []string{"VerifiableCredential", "StatusList2021Credential"},
+		Issuer: "did:example:123",
+		CredentialSubject: &map[string]interface{}{
+			"id":            "https://issuer.example.com/status/1#list",
+			"type":          "StatusList2021",
+			"statusPurpose": purpose,
+			"encodedList":   encodedList,
+		},
+	}
+}
+
+func TestStatusResolver_StatusList2021(t *testing.T) {
+	expired := createStatusListCredential(constant.StatusPurposeRevocation, encodeStatusList(t))
+	expired.ExpirationDate = &models.TimeWithFormat{Time: time.Now().Add(-time.Hour)}
+	server := newStatusServer(t, map[string]interface{}{
+		"/revocation": createStatusListCredential(constant.StatusPurposeRevocation, encodeStatusList(t, 94567)),
+		"/expired":    expired,
+		"/suspension": createStatusListCredential(constant.StatusPurposeSuspension, encodeStatusList(t, 10)),
+		"/bomb":       createStatusListCredential(constant.StatusPurposeRevocation, encodeBomb(t)),
+	})
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		status   models.CredentialStatus
+		expected string
+		err      error
+	}{
+		{"Revoked", models.CredentialStatus{Type: constant.StatusList2021Entry, StatusPurpose: constant.StatusPurposeRevocation, StatusListIndex: "94567", StatusListCredential: server.URL + "/revocation"}, constant.CredentialStatusRevoked, nil},
+		{"Valid", models.CredentialStatus{Type: constant.StatusList2021Entry, StatusPurpose: constant.StatusPurposeRevocation, StatusListIndex: "94566", StatusListCredential: server.URL + "/revocation"}, constant.CredentialStatusIssued, nil},
+		{"Suspended", models.CredentialStatus{Type: constant.StatusList2021Entry, StatusPurpose: constant.StatusPurposeSuspension, StatusListIndex: "10", StatusListCredential: server.URL + "/suspension"}, constant.CredentialStatusSuspended, nil},
+		{"Purpose mismatch", models.CredentialStatus{Type: constant.StatusList2021Entry, StatusPurpose: constant.StatusPurposeSuspension, StatusListIndex: "10", StatusListCredential: server.URL + "/revocation"}, "", models.ErrStatusNotValid},
+		{"Out of range", models.CredentialStatus{Type: constant.StatusList2021Entry, StatusPurpose: constant.StatusPurposeRevocation, StatusListIndex: "131072", StatusListCredential: server.URL + "/revocation"}, "", models.ErrStatusNotValid},
+		{"RevocationList2020", models.CredentialStatus{Type: constant.RevocationList2020Status, RevocationListIndex: "94567", RevocationListCredential: server.URL + "/revocation"}, constant.CredentialStatusRevoked, nil},
+		{"List not found", models.CredentialStatus{Type: constant.StatusList2021Entry, StatusListIndex: "1", StatusListCredential: server.URL + "/missing"}, "", models.ErrStatusNotValid},
+		{"Expired list", models.CredentialStatus{Type: constant.StatusList2021Entry, StatusPurpose: constant.StatusPurposeRevocation, StatusListIndex: "94567", StatusListCredential: server.URL + "/expired"}, "", models.ErrStatusNotValid},
+		{"Oversized list", models.CredentialStatus{Type: constant.StatusList2021Entry, StatusListIndex: "1", StatusListCredential: server.URL + "/bomb"}, "", models.ErrStatusNotValid},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status := test.status
+			cred := &models.VerifiableCredential{Id: "cred:example:1", Issuer: "did:example:123", CredentialStatus: &status}
+
+			current, err := resolveStatus(t, cred)
+			assert.Equal(t, test.err, err)
+			assert.Equal(t, test.expected, current)
+		})
+	}
+
+	//Lists published by others than the issuer can't tell the status of the credential
+	cred := &models.VerifiableCredential{Id: "cred:example:1", Issuer: "did:example:456", CredentialStatus: &models.CredentialStatus{
+		Type: constant.StatusList2021Entry, StatusPurpose: constant.StatusPurposeRevocation, StatusListIndex: "94567", StatusListCredential: server.URL + "/revocation",
+	}}
+	_, err := resolveStatus(t, cred)
+	assert.Equal(t, models.ErrStatusNotValid, err)
+
+	_, err = difValidator.getStatusResolver("UnknownStatus2030")
+	assert.Equal(t, models.ErrUnsupportedStatus, err)
+}
+
+func TestStatusResolver_StatusList2021JWT(t *testing.T) {
+	issuerPub, issuerKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	holderKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	validator := createJWTValidator(t, issuerPub, &holderKey.PublicKey)
+
+	encodeList := func(key ed25519.PrivateKey) string {
+		return encodeJWT(t, map[string]interface{}{"alg": "EdDSA", "kid": jwtIssuer + "#key-1"}, map[string]interface{}{
+			"iss": jwtIssuer,
+			"jti": "https://issuer.example.com/status/1",
+			"nbf": time.Now().Add(-time.Hour).Unix(),
+			"vc": map[string]interface{}{
+				"type": []string{"VerifiableCredential", "StatusList2021Credential"},
+				"credentialSubject": map[string]interface{}{
+					"id":            "https://issuer.example.com/status/1#list",
+					"type":          "StatusList2021",
+					"statusPurpose": constant.StatusPurposeRevocation,
+					"encodedList":   encodeStatusList(t, 94567),
+				},
+			},
+		}, func(input []byte) []byte {
+			return ed25519.Sign(key, input)
+		})
+	}
+	server := newStatusServer(t, map[string]interface{}{
+		"/signed": encodeList(issuerKey),
+		"/forged": encodeList(otherKey),
+	})
+	defer server.Close()
+
+	resolver, err := validator.getStatusResolver(constant.StatusList2021Entry)
+	assert.NoError(t, err)
+	cred := &models.VerifiableCredential{Id: "cred:example:1", Issuer: jwtIssuer, CredentialStatus: &models.CredentialStatus{
+		Type: constant.StatusList2021Entry, StatusPurpose: constant.StatusPurposeRevocation, StatusListIndex: "94567", StatusListCredential: server.URL + "/signed",
+	}}
+	current, err := resolver.ResolveStatus(nil, cred, "")
+	assert.NoError(t, err)
+	assert.Equal(t, constant.CredentialStatusRevoked, current)
+
+	//A list signed by a key the issuer doesn't hold must not be trusted
+	cred.CredentialStatus.StatusListCredential = server.URL + "/forged"
+	_, err = resolver.ResolveStatus(nil, cred, "")
+	assert.Error(t, err)
+}
+
+// encodeBomb compresses a list far larger than any status list, which a few KB are enough to transport
+func encodeBomb(t *testing.T) string {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zeros := make([]byte, 1<<20)
+	for i := 0; i <= maxStatusListSize>>20; i++ {
+		_, err := zw.Write(zeros)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestStatusResolver_Gataca(t *testing.T) {
+	server := newStatusServer(t, map[string]interface{}{
+		"/status": models.VerifiableStatus{
+			Id: "status",
+			VerifiableCredential: []models.VerifiableCredentialStatus{
+				{Claim: &models.Claim{Id: "cred:example:1", CurrentStatus: constant.CredentialStatusRevoked}},
+			},
+		},
+	})
+	defer server.Close()
+
+	cred := &models.VerifiableCredential{Id: "cred:example:1", CredentialStatus: &models.CredentialStatus{
+		Id:   server.URL + "/status",
+		Type: constant.CredentialStatusList2017,
+	}}
+	current, err := resolveStatus(t, cred)
+	assert.NoError(t, err)
+	assert.Equal(t, constant.CredentialStatusRevoked, current)
+
+	cred.Id = "cred:example:2"
+	_, err = resolveStatus(t, cred)
+	assert.Equal(t, models.ErrStatusNotValid, err)
+}
+
+func TestDIFValidatorService_ValidateStatusList(t *testing.T) {
+	server := newStatusServer(t, map[string]interface{}{
+		"/revocation": createStatusListCredential(constant.StatusPurposeRevocation, encodeStatusList(t, 5)),
+	})
+	defer server.Close()
+	presentationDefinition := createPresentationDefinition(t)
+	vp := createVerifiablePresentation(t)
+	vp.VerifiableCredential[0].CredentialStatus = &models.CredentialStatus{
+		Id:                   server.URL + "/revocation#5",
+		Type:                 constant.StatusList2021Entry,
+		StatusPurpose:        constant.StatusPurposeRevocation,
+		StatusListIndex:      "5",
+		StatusListCredential: server.URL + "/revocation",
+	}
+
+	res, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.Error(t, err)
+	assert.Equal(t, models.ErrStatusRevoked, err)
+	assert.NotContains(t, res.Checks, CheckStatus)
+
+	vp.VerifiableCredential[0].CredentialStatus.StatusListIndex = "6"
+	res, err = difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.NoError(t, err)
+	assert.Contains(t, res.Checks, CheckStatus)
+}
+
+func TestDIFValidatorService_ValidateStatusPolicy(t *testing.T) {
+	server := newStatusServer(t, map[string]interface{}{
+		"/status": models.VerifiableStatus{
+			Id: "status",
+			VerifiableCredential: []models.VerifiableCredentialStatus{
+				{Claim: &models.Claim{Id: "cred:example:aeourhiuq4q38wq8q3", CurrentStatus: constant.CredentialStatusPending}},
+				{Claim: &models.Claim{Id: "cred:example:employee12345", CurrentStatus: constant.CredentialStatusSuspended}},
+			},
+		},
+	})
+	defer server.Close()
+	presentationDefinition := createPresentationDefinition(t)
+	vp := createVerifiablePresentation(t)
+	vp.VerifiableCredential[0].CredentialStatus = &models.CredentialStatus{Id: server.URL + "/status", Type: constant.CredentialStatusList2017}
+	policy := &models.VerificationPolicy{ToleratedStatuses: []string{constant.CredentialStatusPending}}
+
+	res, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.Error(t, err)
+	assert.Equal(t, models.ErrStatusPending, err)
+	assert.NotContains(t, res.Checks, CheckStatus)
+
+	res, err = difValidator.ValidatePresentationResponseWithPolicy(nil, presentationDefinition, vp, "", policy)
+	assert.NoError(t, err)
+	assert.Contains(t, res.Checks, CheckStatus)
+	assert.Contains(t, res.Warnings, "Credential cred:example:aeourhiuq4q38wq8q3 accepted with status PENDING: credential issuance is pending")
+
+	vp.VerifiableCredential[1].CredentialStatus = &models.CredentialStatus{Id: server.URL + "/status", Type: constant.CredentialStatusList2017}
+	res, err = difValidator.ValidatePresentationResponseWithPolicy(nil, presentationDefinition, vp, "", policy)
+	assert.Error(t, err)
+	assert.Equal(t, models.ErrStatusSuspended, err)
+	assert.Contains(t, res.Errors, "Credential cred:example:employee12345 status couldn't be verified: credential is suspended")
+}
@@ -1,12 +1,13 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
-	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -16,8 +17,6 @@ import (
 	"github.com/gataca-io/vui-core/models"
 	"github.com/gataca-io/vui-core/tools"
 
-	"github.com/ohler55/ojg/jp"
-
 	converter "github.com/gookit/filter"
 )
 
@@ -34,24 +33,129 @@ const (
 	CheckSchema       = "credentialSchema"
 	CheckIssuer       = "issuer"
 	CheckIdentity     = "identityVerification"
+	CheckDisclosure   = "limitDisclosure"
+	CheckHolder       = "subjectIsHolder"
+	CheckField        = "field"
+	CheckExpiry       = "expiry"
+	CheckValidity     = "validity"
+	CheckFormat       = "format"
+	CheckSameSubject  = "sameSubject"
+	CheckAssurance    = "levelOfAssurance"
+	CheckTrust        = "issuerTrust"
+	CheckChallenge    = "challenge"
 
-	thresholdVCStatusCheck = 5 * time.Second
+	thresholdVCStatusCheck   = 5 * time.Second
+	defaultValidationWorkers = 4
+	defaultClockSkew         = time.Minute
+	defaultSecondFactorAge   = 5 * time.Minute
 )
 
 type ValidatorServiceDIF struct {
-	ssiS SSIService
-	jVal JSONValidator
+	ssiS            SSIService
+	jVal            JSONValidator
+	didS            DidService
+	govS            GovernanceService
+	maxWorkers      int
+	statusResolvers map[string]StatusResolver
+	policy          *models.VerificationPolicy
+	clock           func() time.Time
+	clockSkew       time.Duration
+	secondFactorAge time.Duration
+	version         string
+	factors         []*regexp.Regexp
+}
+
+// DIFValidatorOption configures optional dependencies and behaviour of the DIF validator
+type DIFValidatorOption func(vs *ValidatorServiceDIF)
+
+// WithDidService sets the resolver used to discover the keys controlled by credential subjects
+func WithDidService(didService DidService) DIFValidatorOption {
+	return func(vs *ValidatorServiceDIF) {
+		vs.didS = didService
+	}
+}
+
+// WithGovernanceService sets the governance framework queried for the accreditation of issuers
+func WithGovernanceService(govService GovernanceService) DIFValidatorOption {
+	return func(vs *ValidatorServiceDIF) {
+		vs.govS = govService
+	}
+}
+
+// WithMaxWorkers limits the number of submitted credentials validated concurrently
+func WithMaxWorkers(workers int) DIFValidatorOption {
+	return func(vs *ValidatorServiceDIF) {
+		vs.maxWorkers = workers
+	}
+}
+
+// WithPolicy sets the verification policy applied when the caller doesn't provide one
+func WithPolicy(policy *models.VerificationPolicy) DIFValidatorOption {
+	return func(vs *ValidatorServiceDIF) {
+		vs.policy = policy
+	}
+}
+
+// WithClock sets the source of the current time used to check the validity period of credentials
+func WithClock(clock func() time.Time) DIFValidatorOption {
+	return func(vs *ValidatorServiceDIF) {
+		vs.clock = clock
+	}
+}
+
+// WithClockSkew sets the tolerance allowed when comparing the dates of credentials and proofs with the current time
+func WithClockSkew(skew time.Duration) DIFValidatorOption {
+	return func(vs *ValidatorServiceDIF) {
+		vs.clockSkew = skew
+	}
+}
+
+// WithSchemaRegistry resolves the schemas of credentials with the given registry, instead of fetching them
+// from their hosts on every verification
+func WithSchemaRegistry(registry SchemaRegistry) DIFValidatorOption {
+	return func(vs *ValidatorServiceDIF) {
+		vs.jVal = NewJSONValidator(registry)
+	}
+}
+
+// WithSecondFactorMaxAge sets how long after their creation the proofs of second factors are accepted
+func WithSecondFactorMaxAge(maxAge time.Duration) DIFValidatorOption {
+	return func(vs *ValidatorServiceDIF) {
+		vs.secondFactorAge = maxAge
+	}
 }
 
-//TODO Parallelize processment
-func NewDIFValidatorService(ssiService SSIService) Validator {
-	return &ValidatorServiceDIF{
-		ssiS: ssiService,
-		jVal: &jsonValidator{},
+// NewDIFValidatorService creates a validator of DIF presentation submissions.
+// The credentials of a submission are validated concurrently, so the services provided must be safe for concurrent use.
+func NewDIFValidatorService(ssiService SSIService, opts ...DIFValidatorOption) Validator {
+	vs := &ValidatorServiceDIF{
+		ssiS:            ssiService,
+		jVal:            NewJSONValidator(newFetchingSchemaRegistry()),
+		clockSkew:       defaultClockSkew,
+		secondFactorAge: defaultSecondFactorAge,
 	}
+	for _, opt := range opts {
+		opt(vs)
+	}
+	return vs
 }
 
 func (vs *ValidatorServiceDIF) ValidatePresentationResponse(ctx echo.Context, preq models.ExchangeRequest, presp models.ExchangeResponse, requesterVMethod string) (*models.VerificationResult, error) {
+	return vs.validatePresentationResponse(ctx, preq, presp, requesterVMethod)
+}
+
+// ValidatePresentationResponseWithPolicy validates the presentation enforcing the given policy instead of the default one
+func (vs *ValidatorServiceDIF) ValidatePresentationResponseWithPolicy(ctx echo.Context, preq models.ExchangeRequest, presp models.ExchangeResponse, requesterVMethod string, policy *models.VerificationPolicy) (*models.VerificationResult, error) {
+	if policy == nil {
+		return vs.validatePresentationResponse(ctx, preq, presp, requesterVMethod)
+	}
+	//Shallow copy sharing the dependencies, so the policy only applies to this call
+	withPolicy := *vs
+	withPolicy.policy = policy
+	return withPolicy.validatePresentationResponse(ctx, preq, presp, requesterVMethod)
+}
+
+func (vs *ValidatorServiceDIF) validatePresentationResponse(ctx echo.Context, preq models.ExchangeRequest, presp models.ExchangeResponse, requesterVMethod string) (*models.VerificationResult, error) {
 	pd := preq.ToPresentationDefinition()
 	resp := presp.ToPresentation()
 	result := &models.VerificationResult{
@@ -66,39 +170,87 @@ func (vs *ValidatorServiceDIF) ValidatePresentationResponse(ctx echo.Context, pr
 	}
 
 	submission := resp.PresentationSubmission
+	compileDefinition(ctx, pd)
+	vs = vs.forVersion(pd.Version())
+	//The copy belongs to this call, so it can keep the keys of the second factors proved along with the presentation
+	vs.factors = factorPatterns(pd)
 
+	var firstErr error
 	err := vs.validateIds(ctx, result, pd, submission)
-	if err != nil {
+	if vs.failed(&firstErr, err) {
 		return normalizeResult(result), err
 	}
 
-	subject, err := vs.getCredentialSubject(ctx, result, resp.VerifiableCredential)
-	log.CDebug(ctx, "Received credentials from ", subject)
-	if err != nil {
+	subjects := vs.getCredentialSubjects(ctx, result, resp.VerifiableCredential)
+	log.CDebug(ctx, "Received credentials from ", subjects)
+
+	err = vs.validateSubmission(ctx, result, pd, resp, requesterVMethod)
+	if vs.failed(&firstErr, err) {
 		return normalizeResult(result), err
 	}
 
-	err = vs.validateSubmission(ctx, result, pd.InputDescriptors, resp, requesterVMethod)
-	if err != nil {
-		result.Checks = tools.UniqueSlice(result.Checks)
+	err = vs.validateSameSubject(ctx, result, pd, resp, requesterVMethod)
+	if vs.failed(&firstErr, err) {
 		return normalizeResult(result), err
 	}
 
 	err = vs.validateSubmissionRequirements(ctx, result, pd, submission, resp)
-	if err != nil {
-		result.Checks = tools.UniqueSlice(result.Checks)
+	if vs.failed(&firstErr, err) {
 		return normalizeResult(result), err
 	}
 
-	err = vs.ssiS.VerifyPresentation(ctx, resp, requesterVMethod)
+	suite, err := validateProofFormat(ctx, resp.GetProofs(), pd.Format.AllowsPresentationProof, vs.factors)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("Presentation proof %s is not accepted by the definition format", suite))
+		if vs.failed(&firstErr, err) {
+			return normalizeResult(result), err
+		}
+	} else {
+		result.Checks = append(result.Checks, CheckFormat)
+	}
+
+	err = vs.verifyPresentationProof(ctx, resp, requesterVMethod)
 	if err != nil {
 		result.Errors = append(result.Errors, "Verifiable presentation not validated")
+		if vs.failed(&firstErr, err) {
+			return normalizeResult(result), err
+		}
+	} else {
+		result.Checks = append(result.Checks, CheckPresentation)
+		err = validateBinding(ctx, result, pd, resp, vs.factors)
+		if vs.failed(&firstErr, err) {
+			return normalizeResult(result), err
+		}
+	}
+
+	err = vs.validatePresentationValidity(ctx, result, resp)
+	if vs.failed(&firstErr, err) {
 		return normalizeResult(result), err
 	}
-	result.Checks = append(result.Checks, CheckPresentation)
 
 	//TODO: Verify existance of Consent - Outside scope of DIFPE?
-	return normalizeResult(result), nil
+	return normalizeResult(result), firstErr
+}
+
+// forVersion returns a copy of the validator applying the rules of the given version of the specification.
+// PE v1 descriptors select credentials by their schema and their filters keep the leniency of the early drafts,
+// while PE v2 makes schemas optional and evaluates filters strictly as JSON Schema.
+func (vs *ValidatorServiceDIF) forVersion(version string) *ValidatorServiceDIF {
+	versioned := *vs
+	versioned.version = version
+	return &versioned
+}
+
+// failed keeps the first error found by the validations and tells if they must stop on the given error.
+// Validations stop on the first error unless the policy asks to collect all of them.
+func (vs *ValidatorServiceDIF) failed(firstErr *error, err error) bool {
+	if err == nil {
+		return false
+	}
+	if *firstErr == nil {
+		*firstErr = err
+	}
+	return !vs.policy.CollectsAllErrors()
 }
 
 // #########
@@ -115,104 +267,471 @@ func (vs *ValidatorServiceDIF) validateIds(ctx echo.Context, result *models.Veri
 	return nil
 }
 
-func (vs *ValidatorServiceDIF) getCredentialSubject(ctx echo.Context, result *models.VerificationResult, vcs []models.VerifiableCredential) (string, error) {
-	user := ""
+// getCredentialSubjects returns the distinct subjects of the credentials. Presentations may hold credentials of several
+// subjects, like a guardian presenting the credentials of a minor, so their equality is only enforced through the
+// same_subject constraints of the definition.
+func (vs *ValidatorServiceDIF) getCredentialSubjects(ctx echo.Context, result *models.VerificationResult, vcs []models.VerifiableCredential) []string {
+	subjects := []string{}
 	for _, vc := range vcs {
-		subj := *(vc.CredentialSubject)
-		if user == "" {
-			user = subj["id"].(string)
+		if vc.CredentialSubject == nil {
+			log.CWarnf(ctx, "Credential %s has no subject", vc.Id)
+			continue
 		}
-
-		if user != subj["id"] {
-			log.CError(ctx, "Credential subject is not the same for all the credentials")
-			result.Errors = append(result.Errors, "Credential subject is not the same for all the credentials")
-			return "", models.ErrNotMatch
+		subject, _ := (*vc.CredentialSubject)["id"].(string)
+		if subject != "" && !tools.Contains(subjects, subject) {
+			subjects = append(subjects, subject)
 		}
 	}
-	result.Checks = append(result.Checks, CheckSubject)
-	return user, nil
+	if len(subjects) == 1 {
+		result.Checks = append(result.Checks, CheckSubject)
+	}
+	return subjects
+}
+
+// submittedCredential links a credential of the presentation with the descriptor it is submitted for,
+// and the format its proof must follow: the one of the descriptor or else the one of the whole definition.
+type submittedCredential struct {
+	descriptor *models.InputDescriptor
+	credential *models.VerifiableCredential
+	format     *models.Format
+	path       string
 }
 
-func (vs *ValidatorServiceDIF) validateSubmission(ctx echo.Context, result *models.VerificationResult, descriptors []models.InputDescriptor, vp *models.VerifiablePresentation, requesterVMethod string) error {
-	vcused := 0
+func (vs *ValidatorServiceDIF) validateSubmission(ctx echo.Context, result *models.VerificationResult, pd *models.PresentationDefinition, vp *models.VerifiablePresentation, requesterVMethod string) error {
+	var firstErr error
+	submissions := []submittedCredential{}
+	presentation := newSubmittedPresentation(vp, vs.nestedVerifier(ctx, requesterVMethod), vs.factors)
 	for _, submitted := range vp.PresentationSubmission.DescriptorMap {
-		descriptor := findInputDescriptorWithId(descriptors, submitted.ID)
+		descriptor := findInputDescriptorWithId(pd.InputDescriptors, submitted.ID)
 		if descriptor == nil {
 			log.CError(ctx, "Received submission outside of definition: ", submitted.ID)
 			result.Errors = append(result.Errors, "Received submission outside of definition")
-			return models.ErrMissingClaim
+			if vs.failed(&firstErr, models.ErrMissingClaim) {
+				return models.ErrMissingClaim
+			}
+			continue
 		}
-		credData, err := jsonPath(ctx, submitted.Path, vp)
+		cred, err := findSubmittedCredential(ctx, submitted, presentation)
 		if err != nil {
-			log.CError(ctx, "Cannot discover the reference of the submission")
 			result.Errors = append(result.Errors, "Cannot discover the reference of the submission")
-			return models.ErrInvalidFormat
+			if vs.failed(&firstErr, err) {
+				return err
+			}
+			continue
 		}
-		cred := &models.VerifiableCredential{}
-		err = tools.ToInterface(credData[0].(map[string]interface{}), cred)
-		if err != nil {
-			log.CError(ctx, "Cannot discover the reference of the submission")
-			result.Errors = append(result.Errors, "Cannot discover the reference of the submission")
-			return models.ErrInvalidFormat
+		format := descriptor.Format
+		if format == nil {
+			format = pd.Format
 		}
-		err = vs.validateCredentialWithDescriptor(ctx, result, cred, descriptor, requesterVMethod)
-		if err != nil {
-			log.CErrorf(ctx, "Submitted credential %s doesn't satisfy descriptor %s constraints", cred.Id, descriptor.ID)
+		submissions = append(submissions, submittedCredential{descriptor: descriptor, credential: cred, format: format, path: strings.Join(submitted.FullPath(), " > ")})
+	}
+
+	partials, errs := vs.validateSubmittedCredentials(ctx, submissions, vp, requesterVMethod)
+	for i, partial := range partials {
+		result.Checks = append(result.Checks, partial.Checks...)
+		result.Warnings = append(result.Warnings, partial.Warnings...)
+		result.Errors = append(result.Errors, partial.Errors...)
+		result.Descriptors = append(result.Descriptors, partial.Descriptors...)
+		if errs[i] != nil {
+			log.CErrorf(ctx, "Submitted credential %s doesn't satisfy descriptor %s constraints", submissions[i].credential.Id, submissions[i].descriptor.ID)
 			result.Errors = append(result.Errors, "Submitted credentials don't satisfy descriptor requirements")
-			return err
+			if vs.failed(&firstErr, errs[i]) {
+				for _, skipped := range submissions[i+1:] {
+					report := models.NewDescriptorReport(skipped.descriptor.ID, skipped.credential.Id, skipped.path)
+					report.Skip(credentialChecks...)
+					result.Descriptors = append(result.Descriptors, *report)
+				}
+				return errs[i]
+			}
 		}
-		vcused++
 	}
-	if vcused < len(vp.VerifiableCredential) {
-		log.CErrorf(ctx, "Received more credentials %d than required submissions %d ", len(vp.VerifiableCredential), vcused)
+	if len(vp.PresentationSubmission.DescriptorMap) < len(vp.VerifiableCredential) {
+		log.CErrorf(ctx, "Received more credentials %d than required submissions %d ", len(vp.VerifiableCredential), len(vp.PresentationSubmission.DescriptorMap))
 		result.Errors = append(result.Errors, "Received more credentials than required")
-		return models.ErrUnwantedClaim
+		if vs.failed(&firstErr, models.ErrUnwantedClaim) {
+			return models.ErrUnwantedClaim
+		}
+	}
+	if firstErr != nil {
+		return firstErr
 	}
 	result.Checks = append(result.Checks, CheckSubmission)
 	return nil
 }
 
-func (vs *ValidatorServiceDIF) validateCredentialWithDescriptor(ctx echo.Context, result *models.VerificationResult, vc *models.VerifiableCredential, descriptor *models.InputDescriptor, requesterVMethod string) error {
+// validateSameSubject checks the same_subject directives of the descriptors: the credentials submitted for descriptors
+// holding any of the referenced fields must share their subject. Preferred directives only raise warnings.
+func (vs *ValidatorServiceDIF) validateSameSubject(ctx echo.Context, result *models.VerificationResult, pd *models.PresentationDefinition, vp *models.VerifiablePresentation, requesterVMethod string) error {
+	directives := subjectDirectives(pd)
+	if len(directives) == 0 {
+		return nil
+	}
+	presentation := newSubmittedPresentation(vp, vs.nestedVerifier(ctx, requesterVMethod), vs.factors)
+	held := true
+	for _, directive := range directives {
+		subjects := map[string]bool{}
+		for _, submitted := range vp.PresentationSubmission.DescriptorMap {
+			descriptor := findInputDescriptorWithId(pd.InputDescriptors, submitted.ID)
+			if descriptor == nil || descriptor.Constraints == nil || !descriptor.Constraints.HasField(directive.FieldId) {
+				continue
+			}
+			cred, err := findSubmittedCredential(ctx, submitted, presentation)
+			if err != nil || cred.CredentialSubject == nil {
+				continue
+			}
+			subject, _ := (*cred.CredentialSubject)["id"].(string)
+			subjects[subject] = true
+		}
+		if len(subjects) > 1 {
+			if directive.Directive == models.Required {
+				log.CErrorf(ctx, "Credentials with fields %v are not about the same subject", directive.FieldId)
+				result.Errors = append(result.Errors, fmt.Sprintf("Credentials with fields %v are not about the same subject", directive.FieldId))
+				return models.ErrSubjectMismatch
+			}
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Credentials with fields %v are not about the same subject", directive.FieldId))
+			held = false
+		}
+	}
+	if held {
+		result.Checks = append(result.Checks, CheckSameSubject)
+	}
+	return nil
+}
 
-	err := vs.validateSchemas(ctx, result, vc, descriptor.Schema)
-	if err != nil {
-		log.CError(ctx, "Error validating schemas")
-		return err
+// subjectDirectives returns the same_subject directives of every input descriptor
+func subjectDirectives(pd *models.PresentationDefinition) []models.SubjectDirective {
+	directives := []models.SubjectDirective{}
+	for _, descriptor := range pd.InputDescriptors {
+		if descriptor.Constraints != nil {
+			directives = append(directives, descriptor.Constraints.SameSubject...)
+		}
 	}
+	return directives
+}
 
-	err = findIssuerInProofs(ctx, vc, vc.Issuer)
-	if err != nil {
-		log.CErrorf(ctx, "Asserted issuer %s is not proving the credential %s", vc.Issuer, vc.Id)
-		result.Errors = append(result.Errors, "Cannot trust issuer of the credential")
+// submittedPresentation is a presentation where the paths of its submission are evaluated. Its generic tree is only
+// built for paths that don't select one of its credentials directly, and then shared by every descriptor, like the
+// presentations nested in it once their proof is verified.
+type submittedPresentation struct {
+	vp      *models.VerifiablePresentation
+	tree    map[string]interface{}
+	verify  func(nested *models.VerifiablePresentation) error
+	nested  map[string]*submittedPresentation
+	factors []*regexp.Regexp
+}
+
+var credentialPath = regexp.MustCompile(`^\$\.verifiableCredential\[(\d+)\]$`)
+
+// newSubmittedPresentation prepares a presentation to evaluate its submission. The presentations nested in it are
+// checked with verify, and refused when it is nil. Proofs signed by keys matching factors prove second factors,
+// so they don't tell how the presentation was received.
+func newSubmittedPresentation(vp *models.VerifiablePresentation, verify func(nested *models.VerifiablePresentation) error, factors []*regexp.Regexp) *submittedPresentation {
+	return &submittedPresentation{vp: vp, verify: verify, nested: map[string]*submittedPresentation{}, factors: factors}
+}
+
+// nestedVerifier verifies the proof of the presentations nested in a submission, as their credentials are only
+// trusted when their holder proves to present them
+func (vs *ValidatorServiceDIF) nestedVerifier(ctx echo.Context, requesterVMethod string) func(nested *models.VerifiablePresentation) error {
+	return func(nested *models.VerifiablePresentation) error {
+		err := vs.verifyPresentationProof(ctx, nested, requesterVMethod)
 		return err
 	}
-	result.Checks = append(result.Checks, CheckIssuer)
+}
 
-	_, err = vs.ssiS.VerifyCredential(ctx, vc, requesterVMethod, false)
+// findSubmittedCredential returns the credential referenced by a submission descriptor. Descriptors with path_nested
+// are followed level by level: every path is evaluated on the document found by the previous one, which is decoded
+// as a presentation, until the credential of the last level.
+func findSubmittedCredential(ctx echo.Context, submitted models.Descriptor, presentation *submittedPresentation) (*models.VerifiableCredential, error) {
+	level := &submitted
+	for ; level.PathNested != nil; level = level.PathNested {
+		if !level.Format.IsPresentation() {
+			log.CErrorf(ctx, "Submission %s nests credentials in a document of format %s", submitted.ID, level.Format)
+			return nil, models.ErrInvalidFormat
+		}
+		nested, err := presentation.nestedAt(ctx, level)
+		if err != nil {
+			return nil, err
+		}
+		presentation = nested
+	}
+	if cred := presentation.credentialAt(level.Path); cred != nil {
+		return cred, nil
+	}
+	data, err := presentation.find(ctx, level.Path)
 	if err != nil {
-		log.CErrorf(ctx, "Credential %s couldn't be cryptographically validated", vc.Id)
-		result.Errors = append(result.Errors, fmt.Sprintf("Credential %s couldn't be cryptographically validated", vc.Id))
-		return err
+		return nil, err
+	}
+	cred := &models.VerifiableCredential{}
+	if err = decodeSubmittedDocument(data, cred); err != nil {
+		log.CErrorf(ctx, "Cannot decode the credential at %s as %s", level.Path, level.Format)
+		return nil, models.ErrInvalidFormat
 	}
-	result.Checks = append(result.Checks, CheckCredential)
+	return cred, nil
+}
 
-	err = vs.verifyStatus(ctx, result, vc)
+// nestedAt returns the presentation nested at the path of a submission level, verifying its proof the first time
+func (sp *submittedPresentation) nestedAt(ctx echo.Context, level *models.Descriptor) (*submittedPresentation, error) {
+	if nested, ok := sp.nested[level.Path]; ok {
+		return nested, nil
+	}
+	data, err := sp.find(ctx, level.Path)
 	if err != nil {
-		log.CErrorf(ctx, "Credential %s status couldn't be verified", vc.Id)
-		result.Errors = append(result.Errors, fmt.Sprintf("Credential %s status couldn't be verified", vc.Id))
-		return err
+		return nil, err
 	}
-	result.Checks = append(result.Checks, CheckStatus)
+	vp := &models.VerifiablePresentation{}
+	if err = decodeSubmittedDocument(data, vp); err != nil {
+		log.CErrorf(ctx, "Cannot decode the presentation at %s as %s", level.Path, level.Format)
+		return nil, models.ErrInvalidFormat
+	}
+	if sp.verify == nil || vp.GetProofs() == nil || vp.GetProofs().GetProof() == nil {
+		log.CErrorf(ctx, "Presentation at %s has no proof that can be verified", level.Path)
+		return nil, models.ErrInvalidSignature
+	}
+	if err = sp.verify(vp); err != nil {
+		log.CErrorf(ctx, "Proof of the presentation at %s couldn't be verified: %v", level.Path, err)
+		return nil, err
+	}
+	nested := newSubmittedPresentation(vp, sp.verify, nil)
+	sp.nested[level.Path] = nested
+	return nested, nil
+}
 
-	err = vs.validateCredentialConstraints(ctx, result, vc, descriptor.Constraints)
-	if err != nil {
-		log.CErrorf(ctx, "Credential %s didn't match required constraints", vc.Id)
-		return err
+// resolve adapts a submission path to the decoded presentation. Paths of presentations received as JWT may point
+// to the vp claim, which is already decoded into the presentation.
+func (sp *submittedPresentation) resolve(path string) string {
+	if strings.HasPrefix(path, "$.vp.") && findJWTProof(sp.vp.GetProofs(), sp.factors...) != nil {
+		return "$." + strings.TrimPrefix(path, "$.vp.")
 	}
-	return nil
+	return path
+}
+
+// credentialAt returns a copy of the credential selected by a path like $.verifiableCredential[0], so the credentials
+// already decoded with the presentation aren't encoded again. It returns nil for any other path.
+func (sp *submittedPresentation) credentialAt(path string) *models.VerifiableCredential {
+	match := credentialPath.FindStringSubmatch(sp.resolve(path))
+	if match == nil {
+		return nil
+	}
+	i, err := strconv.Atoi(match[1])
+	if err != nil || i >= len(sp.vp.VerifiableCredential) {
+		return nil
+	}
+	cred := sp.vp.VerifiableCredential[i]
+	return &cred
+}
+
+// find returns the document found at a submission path of the presentation
+func (sp *submittedPresentation) find(ctx echo.Context, path string) (interface{}, error) {
+	if sp.tree == nil {
+		tree, err := tools.ToMap(sp.vp)
+		if err != nil {
+			log.CError(ctx, "Cannot convert presentation to map to process it")
+			return nil, models.ErrInvalidFormat
+		}
+		sp.tree = tree
+	}
+	data, err := jsonPath(sp.resolve(path), sp.tree)
+	if err != nil || len(data) == 0 || data[0] == nil {
+		log.CError(ctx, "Cannot discover the reference of the submission")
+		return nil, models.ErrInvalidFormat
+	}
+	return data[0], nil
+}
+
+// decodeSubmittedDocument decodes a document found in the submission, either an embedded object or a JWT,
+// into a credential or a presentation
+func decodeSubmittedDocument(data interface{}, target interface{}) error {
+	switch data.(type) {
+	case string, map[string]interface{}:
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(raw, target)
+	}
+	return models.ErrInvalidFormat
+}
+
+// validateSubmittedCredentials validates every submitted credential against its descriptor using a bounded pool of workers.
+// Each credential gets its own partial result, so they can be merged afterwards in the order of the submission.
+// Credentials placed after a failed one are skipped, as their result would be discarded, unless the policy asks to
+// collect all the errors. All pending work is cancelled when the request ends.
+func (vs *ValidatorServiceDIF) validateSubmittedCredentials(ctx echo.Context, submissions []submittedCredential, vp *models.VerifiablePresentation, requesterVMethod string) ([]*models.VerificationResult, []error) {
+	partials := make([]*models.VerificationResult, len(submissions))
+	errs := make([]error, len(submissions))
+
+	workers := vs.maxWorkers
+	if workers <= 0 {
+		workers = defaultValidationWorkers
+	}
+	reqCtx := requestContext(ctx)
+	var mu sync.Mutex
+	firstFailure := len(submissions)
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i := range submissions {
+		partials[i] = &models.VerificationResult{Checks: []string{}, Errors: []string{}, Warnings: []string{}}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-reqCtx.Done():
+				errs[i] = reqCtx.Err()
+				return
+			}
+			mu.Lock()
+			skip := i > firstFailure && !vs.policy.CollectsAllErrors()
+			mu.Unlock()
+			if skip {
+				return
+			}
+			if reqCtx.Err() != nil {
+				errs[i] = reqCtx.Err()
+				return
+			}
+			report := models.NewDescriptorReport(submissions[i].descriptor.ID, submissions[i].credential.Id, submissions[i].path)
+			err := vs.validateCredentialWithDescriptor(ctx, partials[i], report, submissions[i].credential, submissions[i].descriptor, submissions[i].format, vp, requesterVMethod)
+			partials[i].Descriptors = append(partials[i].Descriptors, *report)
+			if err != nil {
+				errs[i] = err
+				mu.Lock()
+				if i < firstFailure {
+					firstFailure = i
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if reqCtx.Err() != nil {
+		log.CWarnf(ctx, "Validation of submitted credentials interrupted: %v", reqCtx.Err())
+	}
+	return partials, errs
+}
+
+// credentialChecks are the checks performed on every submitted credential, in order
+var credentialChecks = []string{CheckSchema, CheckFormat, CheckIssuer, CheckCredential, CheckValidity, CheckExpiry, CheckStatus, CheckAssurance, CheckTrust, CheckConstraints}
+
+// credentialStep is a check on a credential, with the path of the credential it involves
+type credentialStep struct {
+	path string
+	run  func() error
+}
+
+func (vs *ValidatorServiceDIF) validateCredentialWithDescriptor(ctx echo.Context, result *models.VerificationResult, report *models.DescriptorReport, vc *models.VerifiableCredential, descriptor *models.InputDescriptor, format *models.Format, vp *models.VerifiablePresentation, requesterVMethod string) error {
+	steps := vs.credentialSteps(ctx, result, report, vc, descriptor, format, vp, requesterVMethod)
+	var firstErr error
+	for i, check := range credentialChecks {
+		err := runCheck(result, report, check, steps[check].path, steps[check].run)
+		if vs.failed(&firstErr, err) || (err != nil && check == CheckCredential) {
+			//The checks following the proof rely on the content being authentic, and some of them query the network
+			report.Skip(credentialChecks[i+1:]...)
+			return firstErr
+		}
+	}
+	return firstErr
+}
+
+// credentialSteps returns the checks that can be performed on a credential submitted for a descriptor, by name
+func (vs *ValidatorServiceDIF) credentialSteps(ctx echo.Context, result *models.VerificationResult, report *models.DescriptorReport, vc *models.VerifiableCredential, descriptor *models.InputDescriptor, format *models.Format, vp *models.VerifiablePresentation, requesterVMethod string) map[string]credentialStep {
+	return map[string]credentialStep{
+		CheckSchema: {"$.credentialSchema", func() error {
+			err := vs.validateSchemas(ctx, result, vc, descriptor.Schema)
+			if err != nil {
+				log.CError(ctx, "Error validating schemas")
+			}
+			return err
+		}},
+		CheckFormat: {"$.proof", func() error {
+			suite, err := validateProofFormat(ctx, vc.GetProofs(), format.AllowsCredentialProof, nil)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("Credential %s proof %s is not accepted by the definition format", vc.Id, suite))
+				return err
+			}
+			result.Checks = append(result.Checks, CheckFormat)
+			return nil
+		}},
+		CheckIssuer: {"$.issuer", func() error {
+			err := findIssuerInProofs(ctx, vc, vc.Issuer)
+			if err != nil {
+				log.CErrorf(ctx, "Asserted issuer %s is not proving the credential %s", vc.Issuer, vc.Id)
+				result.Errors = append(result.Errors, "Cannot trust issuer of the credential")
+				return err
+			}
+			result.Checks = append(result.Checks, CheckIssuer)
+			return nil
+		}},
+		CheckCredential: {"$.proof", func() error {
+			err := vs.verifyCredentialProof(ctx, vc, requesterVMethod)
+			if err != nil {
+				log.CErrorf(ctx, "Credential %s couldn't be cryptographically validated", vc.Id)
+				result.Errors = append(result.Errors, fmt.Sprintf("Credential %s couldn't be cryptographically validated", vc.Id))
+				return err
+			}
+			result.Checks = append(result.Checks, CheckCredential)
+			return nil
+		}},
+		CheckValidity: {"$.validFrom", func() error {
+			err := vs.validateValidity(ctx, result, vc)
+			if err != nil {
+				return err
+			}
+			result.Checks = append(result.Checks, CheckValidity)
+			return nil
+		}},
+		CheckExpiry: {"$.expirationDate", func() error {
+			err := vs.validateExpiry(ctx, result, vc)
+			if err != nil {
+				return err
+			}
+			result.Checks = append(result.Checks, CheckExpiry)
+			return nil
+		}},
+		CheckStatus: {"$.credentialStatus", func() error {
+			err := vs.verifyStatus(ctx, result, vc, requesterVMethod)
+			if err != nil {
+				log.CErrorf(ctx, "Credential %s status couldn't be verified", vc.Id)
+				result.Errors = append(result.Errors, fmt.Sprintf("Credential %s status couldn't be verified: %s", vc.Id, err.Error()))
+				return err
+			}
+			result.Checks = append(result.Checks, CheckStatus)
+			return nil
+		}},
+		CheckAssurance: {"$.evidence", func() error {
+			return vs.validateAssurance(ctx, result, vc, descriptor.LevelOfAssurance)
+		}},
+		CheckTrust: {"$.issuer", func() error {
+			return vs.validateIssuerTrust(ctx, result, vc, descriptor)
+		}},
+		CheckConstraints: {"", func() error {
+			err := vs.validateCredentialConstraints(ctx, result, report, vc, vp, descriptor.Constraints)
+			if err != nil {
+				log.CErrorf(ctx, "Credential %s didn't match required constraints", vc.Id)
+			}
+			return err
+		}},
+	}
+}
+
+// runCheck performs a check on a credential and records its outcome in the report, along with the messages it raised
+func runCheck(result *models.VerificationResult, report *models.DescriptorReport, check, path string, step func() error) error {
+	errs, warnings := len(result.Errors), len(result.Warnings)
+	start := time.Now()
+	err := step()
+	var messages []string
+	messages = append(messages, result.Errors[errs:]...)
+	messages = append(messages, result.Warnings[warnings:]...)
+	report.AddCheck(check, path, time.Since(start), err, messages)
+	return err
 }
 
 func (vs *ValidatorServiceDIF) validateSchemas(ctx echo.Context, result *models.VerificationResult, vc *models.VerifiableCredential, requestedSchemas []models.Schema) error {
+	if len(requestedSchemas) == 0 && vs.version != models.PEVersion1 {
+		//Since PE v2 descriptors select credentials by their fields, any schema is accepted
+		return nil
+	}
 	found := false
 	for _, schema := range requestedSchemas {
 		if vc.CredentialSchema != nil {
@@ -242,7 +761,7 @@ func (vs *ValidatorServiceDIF) validateSchemas(ctx echo.Context, result *models.
 			err := vs.jVal.ValidateWithRef(vc, schema.URI) //No schema given, try to see if matching expected schema
 			if err == nil {
 				found = true
-				result.Warnings = append(result.Errors, "Credential Schema is matching but wasn't explicitely stated")
+				result.Warnings = append(result.Warnings, "Credential Schema is matching but wasn't explicitely stated")
 				break
 			}
 			if err != nil && schema.Required {
@@ -261,18 +780,31 @@ func (vs *ValidatorServiceDIF) validateSchemas(ctx echo.Context, result *models.
 	return nil
 }
 
-func (vs *ValidatorServiceDIF) validateCredentialConstraints(ctx echo.Context, result *models.VerificationResult, vc *models.VerifiableCredential, constraints *models.Constraints) error {
+func (vs *ValidatorServiceDIF) validateCredentialConstraints(ctx echo.Context, result *models.VerificationResult, report *models.DescriptorReport, vc *models.VerifiableCredential, vp *models.VerifiablePresentation, constraints *models.Constraints) error {
 	if constraints == nil {
 		result.Warnings = append(result.Warnings, "No constraints required validation")
 		return nil
 	}
-	if constraints.SubjectIsHolder != nil && *constraints.SubjectIsHolder == models.Required {
-		//TODO
-		result.Warnings = append(result.Warnings, "Subject is holder constraint required but not implemented yet")
-		log.Debug("Subject is holder constraint not implemented yet")
+	if directive := constraints.HolderDirective(); directive != nil {
+		err := vs.validateSubjectIsHolder(ctx, vc, vp)
+		if err != nil {
+			if *directive == models.Required {
+				log.CErrorf(ctx, "Subject of credential %s is not the holder of the presentation", vc.Id)
+				result.Errors = append(result.Errors, fmt.Sprintf("Subject of credential %s is not the holder of the presentation", vc.Id))
+				return err
+			}
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Subject of credential %s couldn't be bound to the holder of the presentation", vc.Id))
+		} else {
+			result.Checks = append(result.Checks, CheckHolder)
+		}
 	}
 	if constraints.SubjectIsIssuer != nil && *constraints.SubjectIsIssuer == models.Required {
-		subject := (*vc.CredentialSubject)["id"].(string)
+		if vc.CredentialSubject == nil {
+			log.CErrorf(ctx, "Credential %s has no subject to bind with the issuer", vc.Id)
+			result.Errors = append(result.Errors, "Subject is not issuer of the credential")
+			return models.ErrHolderBinding
+		}
+		subject, _ := (*vc.CredentialSubject)["id"].(string)
 		err := findIssuerInProofs(ctx, vc, subject)
 		if err != nil {
 			log.CError(ctx, "Subject is not issuer of the credential")
@@ -280,23 +812,31 @@ func (vs *ValidatorServiceDIF) validateCredentialConstraints(ctx echo.Context, r
 			return err
 		}
 	}
-	if constraints.LimitDisclosure {
-		//TODO check if more data than required is being checked
-		log.Debug("Limit disclosure constraint not implemented yet")
-		result.Warnings = append(result.Warnings, "Limit disclosure constraint required but not implemented yet")
-	}
-
-	err := vs.validateFieldConstraint(ctx, vc, constraints.Fields)
+	err := vs.validateFieldConstraint(ctx, result, report, vc, constraints.Fields)
 	if err != nil {
 		log.CError(ctx, "Field constraint not validated")
 		result.Errors = append(result.Errors, "Field constraint not validated")
 		return err
 	}
+	if constraints.LimitDisclosure.IsLimited() {
+		err = vs.validateLimitDisclosure(ctx, vc, constraints.Fields)
+		if err != nil {
+			if constraints.LimitDisclosure.Preference == models.Preferred {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Credential %s discloses more claims than requested", vc.Id))
+			} else {
+				log.CErrorf(ctx, "Credential %s discloses more claims than requested", vc.Id)
+				result.Errors = append(result.Errors, fmt.Sprintf("Credential %s discloses more claims than requested", vc.Id))
+				return err
+			}
+		} else {
+			result.Checks = append(result.Checks, CheckDisclosure)
+		}
+	}
 	result.Checks = append(result.Checks, CheckConstraints)
 	return nil
 }
 
-func (vs *ValidatorServiceDIF) validateFieldConstraint(ctx echo.Context, vc *models.VerifiableCredential, fieldConstraint []models.Field) error {
+func (vs *ValidatorServiceDIF) validateFieldConstraint(ctx echo.Context, result *models.VerificationResult, report *models.DescriptorReport, vc *models.VerifiableCredential, fieldConstraint []models.Field) error {
 	if len(fieldConstraint) == 0 {
 		return nil
 	}
@@ -305,103 +845,336 @@ func (vs *ValidatorServiceDIF) validateFieldConstraint(ctx echo.Context, vc *mod
 		log.CError(ctx, "Cannot convert vc to map to process it")
 		return err
 	}
+	var firstErr error
 	for _, field := range fieldConstraint {
-		//TODO implement filtering
-		found := false
-		datas := findInPaths(ctx, mappedCred, field.Path)
-		for _, data := range datas {
-			if data != nil {
-				found = true
-				err = vs.validateFilter(ctx, data, field.Filter)
-				if err != nil {
-					log.CError(ctx, "Filtering condition not accepted")
-					return err
+		field := field
+		path, data := findFieldValue(ctx, mappedCred, &field)
+		err = runCheck(result, report, CheckField, path, func() error {
+			if data == nil {
+				if field.Optional {
+					return nil
 				}
-				break
+				if field.Predicate == nil || *field.Predicate == models.Required {
+					log.CErrorf(ctx, "Missing required paths in object %+v, %+v", field.Path, field.Predicate)
+					return models.ErrMissingConstraint
+				}
+				return nil
+			}
+			err := vs.validateFieldValue(ctx, result, vc, &field, data)
+			if err != nil {
+				log.CError(ctx, "Filtering condition not accepted")
+			}
+			return err
+		})
+		if vs.failed(&firstErr, err) {
+			return err
+		}
+	}
+	return firstErr
+}
+
+// findFieldValue returns the first value found in the paths of a field, with the path where it was found.
+// When no value is found, the first path of the field is returned.
+func findFieldValue(ctx echo.Context, mappedCred map[string]interface{}, field *models.Field) (string, interface{}) {
+	for _, path := range field.Path {
+		for _, data := range findInPaths(ctx, mappedCred, []string{path}) {
+			if data != nil {
+				return path, data
 			}
 		}
-		if !found && (field.Predicate == nil || *field.Predicate == models.Required) {
-			log.CErrorf(ctx, "Missing required paths in object %+v, %+v", field.Path, field.Predicate)
+	}
+	if len(field.Path) == 0 {
+		return "", nil
+	}
+	return field.Path[0], nil
+}
+
+// validateFieldValue evaluates the value of a field against its filter. Fields with a predicate can be answered
+// with the boolean result of the filter instead of the value, which is protected by the proof of the issuer.
+func (vs *ValidatorServiceDIF) validateFieldValue(ctx echo.Context, result *models.VerificationResult, vc *models.VerifiableCredential, field *models.Field, data interface{}) error {
+	if isPredicateResult(field, data) {
+		if !data.(bool) {
+			log.CErrorf(ctx, "Predicate of field %+v wasn't fullfilled", field.Path)
 			return models.ErrMissingConstraint
 		}
+		return nil
+	}
+	if field.Predicate != nil && *field.Predicate == models.Required {
+		log.CWarnf(ctx, "Credential %s disclosed the value of the predicate field %+v", vc.Id, field.Path)
+		result.Warnings = append(result.Warnings, fmt.Sprintf("Credential %s disclosed the value of the predicate field %s instead of its result", vc.Id, field.Path[0]))
+	}
+	return vs.validateFilter(ctx, data, field.Filter)
+}
+
+// isPredicateResult tells if the data is the boolean result of a predicate, rather than a value to filter
+func isPredicateResult(field *models.Field, data interface{}) bool {
+	_, isBool := data.(bool)
+	return isBool && field.Predicate != nil && field.Filter != nil && field.Filter.Type != "boolean"
+}
+
+// validateSubjectIsHolder checks that the subject of the credential controls the key of the presentation proof, the one
+// verifyPresentationProof verifies. Other proofs, like the ones of second factors, can't bind the subject. Keys under the
+// subject DID are bound directly, otherwise the subject DID Document is resolved to look for the key.
+func (vs *ValidatorServiceDIF) validateSubjectIsHolder(ctx echo.Context, vc *models.VerifiableCredential, vp *models.VerifiablePresentation) error {
+	var proof *models.Proof
+	if vp != nil {
+		proof = presentationProof(vp, vs.factors)
+	}
+	if vc.CredentialSubject == nil || proof == nil {
+		log.CError(ctx, "Missing subject or presentation proof to bind the holder")
+		return models.ErrHolderBinding
+	}
+	subject, ok := (*vc.CredentialSubject)["id"].(string)
+	if !ok || subject == "" {
+		log.CErrorf(ctx, "Credential %s has no subject to bind with the holder", vc.Id)
+		return models.ErrHolderBinding
+	}
+	creator := proof.GetCreator()
+	if strings.Split(creator, "#")[0] == subject {
+		return nil
+	}
+	if vs.didS == nil {
+		log.CWarnf(ctx, "No DID resolver available to find keys of subject %s", subject)
+		return models.ErrHolderBinding
+	}
+	didDoc, err := vs.didS.GetDID(ctx, subject)
+	if err != nil {
+		log.CErrorf(ctx, "Cannot resolve subject DID %s: %v", subject, err)
+		return models.ErrHolderBinding
+	}
+	if didDoc.GetKeyFromId(creator) != nil {
+		return nil
+	}
+	for _, vm := range didDoc.Authentication {
+		if vm.GetId() == creator {
+			return nil
+		}
+	}
+	log.CErrorf(ctx, "Presentation signer %s is not controlled by subject %s", creator, subject)
+	return models.ErrHolderBinding
+}
+
+// validateLimitDisclosure removes from a copy of the credential every claim reachable by the requested field paths.
+// Any claim left on the credential subject (apart from its id) has been disclosed without being requested.
+func (vs *ValidatorServiceDIF) validateLimitDisclosure(ctx echo.Context, vc *models.VerifiableCredential, fieldConstraint []models.Field) error {
+	mappedCred, err := tools.ToMap(vc)
+	if err != nil {
+		log.CError(ctx, "Cannot convert vc to map to process it")
+		return err
+	}
+	for _, field := range fieldConstraint {
+		for _, path := range field.Path {
+			x, err := compilePath(path)
+			if err != nil {
+				log.CWarnf(ctx, "Cannot parse path %s: %s", path, err.Error())
+				continue
+			}
+			//Paths not matching the credential are not an error, they just don't cover any claim
+			_ = x.Del(mappedCred)
+		}
+	}
+	subject, ok := mappedCred["credentialSubject"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	delete(subject, "id")
+	undisclosed := []string{}
+	for claim, value := range subject {
+		if hasDisclosedData(value) {
+			undisclosed = append(undisclosed, claim)
+		}
+	}
+	if len(undisclosed) > 0 {
+		log.CErrorf(ctx, "Claims %v were disclosed but not requested", undisclosed)
+		return models.ErrLimitDisclosure
 	}
 	return nil
 }
 
+func hasDisclosedData(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case map[string]interface{}:
+		for _, nested := range v {
+			if hasDisclosedData(nested) {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		for _, nested := range v {
+			if hasDisclosedData(nested) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// validateFilter evaluates the data found for a field against its filter as a JSON Schema fragment.
+// Bounds of date filters are compared as instants, as JSON Schema only compares numbers, and patterns are
+// matched with the expressions compiled for the definition. To keep the PE v1 definitions working, their patterns
+// are also matched against non-string values and their numeric filters accept numbers encoded as strings.
 func (vs *ValidatorServiceDIF) validateFilter(ctx echo.Context, data interface{}, filter *models.Filter) error {
-	strData, ok := data.(string)
-	if filter.Format == "string" && !ok {
-		log.CError(ctx, "Cannot convert expected data to string")
-		return models.ErrInvalidFormat
+	if filter == nil {
+		return nil
 	}
-	if filter.Pattern != "" {
-		if filter.Type != "string" {
-			strData = converter.MustString(data)
+	legacy := vs.version == models.PEVersion1
+	if strData, ok := data.(string); ok && legacy && (filter.Type == "number" || filter.Type == "integer") {
+		if nbData, err := strconv.ParseFloat(strData, 64); err == nil {
+			data = nbData
 		}
-		matched, err := regexp.Match(filter.Pattern, []byte(strData))
-		if err != nil || !matched {
+	}
+	schema, err := filterSchema(filter, false)
+	if err != nil {
+		log.CErrorf(ctx, "Filter can't be evaluated: %v", err)
+		return models.ErrMissingConstraint
+	}
+	err = validateGoValues(schema, data)
+	if err != nil {
+		log.CError(ctx, "Filter schema wasn't fullfilled")
+		return models.ErrMissingConstraint
+	}
+	if _, ok := data.(string); filter.Pattern != "" && (ok || legacy) {
+		pattern, err := compilePattern(filter.Pattern)
+		if err != nil || !pattern.MatchString(converter.MustString(data)) {
 			log.CError(ctx, "Pattern wasn't fullfilled")
 			return models.ErrMissingConstraint
 		}
 	}
+	if isDateFormat(filter.Format) {
+		err = validateDateFilter(ctx, data, filter)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterSchema translates a filter to the JSON Schema keywords evaluated by the schema engine, with its not and
+// contains filters as nested schemas. The pattern and the date bounds of the filter itself are left out, as they are
+// evaluated by validateFilter. Date bounds can't be expressed in JSON Schema, so nested filters can't have them.
+// Bounds that are neither numbers nor dates make the filter fail, instead of comparing the data with a default bound.
+func filterSchema(filter *models.Filter, nested bool) (map[string]interface{}, error) {
+	schema := map[string]interface{}{}
+	if filter.Type != "" {
+		schema["type"] = filter.Type
+	}
+	if filter.Format != "" && filter.Format != "date" {
+		schema["format"] = filter.Format
+	}
+	if nested && filter.Pattern != "" {
+		schema["pattern"] = filter.Pattern
+	}
+	if filter.MinLength > 0 {
+		schema["minLength"] = filter.MinLength
+	}
+	if filter.MaxLength > 0 {
+		schema["maxLength"] = filter.MaxLength
+	}
+	if filter.Const != nil {
+		schema["const"] = filter.Const
+	}
 	if len(filter.Enum) > 0 {
-		found := false
-		for _, v := range filter.Enum {
-			if data == v {
-				found = true
-				break
+		schema["enum"] = filter.Enum
+	}
+	bounds := map[string]models.StringOrInteger{
+		"minimum":          filter.Minimum,
+		"maximum":          filter.Maximum,
+		"exclusiveMinimum": filter.ExclusiveMinimum,
+		"exclusiveMaximum": filter.ExclusiveMaximum,
+	}
+	for keyword, bound := range bounds {
+		if bound == nil {
+			continue
+		}
+		if isDateFormat(filter.Format) {
+			strBound, ok := bound.(string)
+			if !ok || nested {
+				return nil, fmt.Errorf("%s %v can't bound a nested date", keyword, bound)
 			}
+			if _, err := parseDate(strBound); err != nil {
+				return nil, fmt.Errorf("%s %s is not a date", keyword, strBound)
+			}
+			continue
 		}
-		if !found {
-			log.CError(ctx, "Enum condition wasn't fullfilled")
-			return models.ErrMissingConstraint
+		number, ok := filterBound(bound)
+		if !ok {
+			return nil, fmt.Errorf("%s %v is not a number", keyword, bound)
 		}
+		schema[keyword] = number
 	}
-	if filter.Const != nil {
-		if data != filter.Const {
-			log.CError(ctx, "Value didn't match constant")
-			return models.ErrMissingConstraint
+	for keyword, nestedFilter := range map[string]*models.Filter{"not": filter.Not, "contains": filter.Contains} {
+		if nestedFilter == nil {
+			continue
 		}
-	}
-	if filter.MinLength > 0 {
-		if len(strData) < filter.MinLength {
-			log.CError(ctx, "Value didn't have required min length")
-			return models.ErrMissingConstraint
+		nestedSchema, err := filterSchema(nestedFilter, true)
+		if err != nil {
+			return nil, err
 		}
+		schema[keyword] = nestedSchema
 	}
-	if filter.MaxLength > 0 {
-		if len(strData) > filter.MaxLength {
-			log.CError(ctx, "Value didn't have required max length")
-			return models.ErrMissingConstraint
-		}
+	return schema, nil
+}
+
+// filterBound returns the number of a numeric bound, which definitions may give as a string
+func filterBound(bound models.StringOrInteger) (float64, bool) {
+	switch b := bound.(type) {
+	case float64:
+		return b, true
+	case int:
+		return float64(b), true
+	case int64:
+		return float64(b), true
+	case string:
+		number, err := strconv.ParseFloat(b, 64)
+		return number, err == nil
 	}
-	if filter.Not != nil {
-		err := vs.validateFilter(ctx, data, filter.Not)
-		if err == nil {
-			log.CError(ctx, "Negative constraint was successfully evaluated")
+	return 0, false
+}
+
+func isDateFormat(format string) bool {
+	return format == "date" || format == "date-time"
+}
+
+// validateDateFilter checks that the data is a date inside the bounds of the filter
+func validateDateFilter(ctx echo.Context, data interface{}, filter *models.Filter) error {
+	strData, ok := data.(string)
+	if !ok {
+		if filter.Type == "string" {
+			log.CError(ctx, "Cannot convert expected data to date")
 			return models.ErrMissingConstraint
 		}
+		return nil
+	}
+	t, err := parseDate(strData)
+	if err != nil {
+		log.CError(ctx, "Cannot convert expected data to date")
+		return models.ErrMissingConstraint
 	}
 	if filter.Maximum != nil {
-		err := interfaceComparison(ctx, filter.Format, data, filter.Maximum, ">=")
+		err := dateComparison(ctx, t, filter.Maximum, ">=")
 		if err != nil {
 			return err
 		}
 	}
 	if filter.Minimum != nil {
-		err := interfaceComparison(ctx, filter.Format, data, filter.Minimum, "<=")
+		err := dateComparison(ctx, t, filter.Minimum, "<=")
 		if err != nil {
 			return err
 		}
 	}
 	if filter.ExclusiveMaximum != nil {
-		err := interfaceComparison(ctx, filter.Format, data, filter.ExclusiveMaximum, ">")
+		err := dateComparison(ctx, t, filter.ExclusiveMaximum, ">")
 		if err != nil {
 			return err
 		}
 	}
 	if filter.ExclusiveMinimum != nil {
-		err := interfaceComparison(ctx, filter.Format, data, filter.ExclusiveMinimum, "<")
+		err := dateComparison(ctx, t, filter.ExclusiveMinimum, "<")
 		if err != nil {
 			return err
 		}
@@ -409,112 +1182,118 @@ func (vs *ValidatorServiceDIF) validateFilter(ctx echo.Context, data interface{}
 	return nil
 }
 
-func interfaceComparison(ctx echo.Context, format string, data, comparator interface{}, operation string) error {
-	switch format {
-	case "date":
-		t, err := time.Parse(time.RFC3339, data.(string))
-		if err != nil {
-			log.CError(ctx, "Cannot convert expected data to date")
-			return err
-		}
-		ct, err := time.Parse(time.RFC3339, comparator.(string))
-		if err != nil {
-			log.CError(ctx, "Cannot convert expected data to date")
-			return err
-		}
-		switch operation {
-		case ">=":
-			if ct.Before(t) {
-				log.CError(ctx, "Time max or equal constraint was successfully evaluated")
-				return models.ErrMissingConstraint
-			}
-		case ">":
-			if !t.Before(ct) {
-				log.CError(ctx, "Time max constraint was successfully evaluated")
-				return models.ErrMissingConstraint
-			}
-		case "<=":
-			if ct.After(t) {
-				log.CError(ctx, "Time min or equal constraint was successfully evaluated")
-				return models.ErrMissingConstraint
-			}
-		case "<":
-			if !t.After(ct) {
-				log.CError(ctx, "Time min constraint was successfully evaluated")
-				return models.ErrMissingConstraint
-			}
+func dateComparison(ctx echo.Context, t time.Time, comparator interface{}, operation string) error {
+	strComparator, ok := comparator.(string)
+	if !ok {
+		log.CError(ctx, "Cannot convert date bound to date")
+		return models.ErrMissingConstraint
+	}
+	ct, err := parseDate(strComparator)
+	if err != nil {
+		log.CError(ctx, "Cannot convert date bound to date")
+		return models.ErrMissingConstraint
+	}
+	switch operation {
+	case ">=":
+		if ct.Before(t) {
+			log.CError(ctx, "Time max or equal constraint was successfully evaluated")
+			return models.ErrMissingConstraint
 		}
-	default:
-		//Ensure coversions
-		nbData, ok := data.(float64)
-		if !ok && reflect.TypeOf(data).String() == "string" {
-			nbData = converter.MustFloat(data.(string))
+	case ">":
+		if !t.Before(ct) {
+			log.CError(ctx, "Time max constraint was successfully evaluated")
+			return models.ErrMissingConstraint
 		}
-		nbComparator, ok := comparator.(float64)
-		if !ok && reflect.TypeOf(comparator).String() == "string" {
-			nbComparator = converter.MustFloat(comparator.(string))
+	case "<=":
+		if ct.After(t) {
+			log.CError(ctx, "Time min or equal constraint was successfully evaluated")
+			return models.ErrMissingConstraint
 		}
-		switch operation {
-		case ">=":
-			if nbData < nbComparator {
-				log.CError(ctx, "Time max or equal constraint was successfully evaluated")
-				return models.ErrMissingConstraint
-			}
-		case ">":
-			if !(nbData > nbComparator) {
-				log.CError(ctx, "Time max constraint was successfully evaluated")
-				return models.ErrMissingConstraint
-			}
-		case "<=":
-			if nbData > nbComparator {
-				log.CError(ctx, "Time min or equal constraint was successfully evaluated")
-				return models.ErrMissingConstraint
-			}
-		case "<":
-			if !(nbData < nbComparator) {
-				log.CError(ctx, "Time min constraint was successfully evaluated")
-				return models.ErrMissingConstraint
-			}
+	case "<":
+		if !t.After(ct) {
+			log.CError(ctx, "Time min constraint was successfully evaluated")
+			return models.ErrMissingConstraint
 		}
 	}
 	return nil
 }
 
+// parseDate accepts full timestamps and dates, with or without zero padding
+func parseDate(date string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, date)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-1-2", date)
+}
+
 func (vs *ValidatorServiceDIF) validateSubmissionRequirements(ctx echo.Context, result *models.VerificationResult, pd *models.PresentationDefinition, sub *models.PresentationSubmission, resp *models.VerifiablePresentation) error {
-	for _, req := range pd.SubmissionRequirements {
-		if req.Name == "Identity verification" {
-			err := vs.validateSecondFactors(ctx, result, pd, resp)
-			if err != nil {
-				log.CError(ctx, "Could not validate submission identity verification: ", req.Name)
-				return err
+	var firstErr error
+	proved := vs.proveMechanisms(ctx, pd, resp)
+	if len(pd.SubmissionRequirements) == 0 {
+		//Without requirements every descriptor proved by the presentation itself must be satisfied
+		for _, descriptor := range pd.InputDescriptors {
+			if err := proved[descriptor.ID]; err != nil {
+				result.Errors = append(result.Errors, err.Error())
+				if vs.failed(&firstErr, err) {
+					return err
+				}
 			}
-			result.Checks = append(result.Checks, CheckIdentity)
-		} else {
-			err := vs.validateRequirement(ctx, result, &req, pd, sub)
-			if err != nil {
-				log.CError(ctx, "Could not validate submission requirement: ", req.Name)
+		}
+	}
+	for _, req := range pd.SubmissionRequirements {
+		err := vs.validateRequirement(ctx, result, &req, pd, sub, proved)
+		if err != nil {
+			log.CError(ctx, "Could not validate submission requirement: ", req.Name)
+			if vs.failed(&firstErr, err) {
 				return err
 			}
 		}
 	}
+	if firstErr != nil {
+		return firstErr
+	}
+	if len(proved) > 0 {
+		result.Checks = append(result.Checks, CheckIdentity)
+	}
 	result.Checks = append(result.Checks, CheckRequirements)
 	return nil
 }
 
-func (vs *ValidatorServiceDIF) validateRequirement(ctx echo.Context, result *models.VerificationResult, req *models.SubmissionRequirement, pd *models.PresentationDefinition, subs *models.PresentationSubmission) error {
+// validateRequirement counts the descriptors of a requirement satisfied by the submitted credentials or, for
+// descriptors with a mechanism, by the proofs of the presentation, and checks the count against its rule
+func (vs *ValidatorServiceDIF) validateRequirement(ctx echo.Context, result *models.VerificationResult, req *models.SubmissionRequirement, pd *models.PresentationDefinition, subs *models.PresentationSubmission, proved map[string]error) error {
 	if len(req.FromNested) > 0 {
+		count := 0
+		var branchErrors []string
 		for _, nested := range req.FromNested {
-			err := vs.validateRequirement(ctx, result, &nested, pd, subs)
+			branchResult := &models.VerificationResult{}
+			err := vs.validateRequirement(ctx, branchResult, &nested, pd, subs, proved)
 			if err != nil {
-				return err
+				branchErrors = append(branchErrors, branchResult.Errors...)
+				continue
 			}
+			count++
 		}
-		return nil
+		err := applyRequirementRule(ctx, result, req, count, len(req.FromNested))
+		if err != nil {
+			result.Errors = append(result.Errors, branchErrors...)
+		}
+		return err
 	}
 	group := req.From
 	ids := filterInputDescriptorsIdsInGroup(pd.InputDescriptors, group)
 	count := 0
+	var proofErr error
 	for _, id := range ids {
+		if err, ok := proved[id]; ok {
+			if err == nil {
+				count++
+			} else if proofErr == nil {
+				proofErr = err
+			}
+			continue
+		}
 		for _, sub := range subs.DescriptorMap {
 			if sub.ID == id {
 				count++
@@ -522,32 +1301,44 @@ func (vs *ValidatorServiceDIF) validateRequirement(ctx echo.Context, result *mod
 			}
 		}
 	}
+	err := applyRequirementRule(ctx, result, req, count, len(ids))
+	if err != nil && proofErr != nil {
+		//The proof missing tells better than the count why the requirement isn't satisfied
+		result.Errors = append(result.Errors, proofErr.Error())
+		return proofErr
+	}
+	return err
+}
+
+// applyRequirementRule checks the number of satisfied elements of a requirement, either descriptors or nested
+// requirements, against its rule
+func applyRequirementRule(ctx echo.Context, result *models.VerificationResult, req *models.SubmissionRequirement, count, total int) error {
 	switch req.Rule {
 	case models.All:
-		if count < len(ids) {
-			log.CErrorf(ctx, "Required requirement %s wasn't satisfied: only %d out of %d satisfy the condition, not all ", req.Name, count, len(ids))
-			result.Errors = append(result.Errors, fmt.Sprintf("Required requirement %s wasn't satisfied: only %d out of %d satisfy the condition, not all ", req.Name, count, len(ids)))
+		if count < total {
+			log.CErrorf(ctx, "Required requirement %s wasn't satisfied: only %d out of %d satisfy the condition, not all ", req.Name, count, total)
+			result.Errors = append(result.Errors, fmt.Sprintf("Required requirement %s wasn't satisfied: only %d out of %d satisfy the condition, not all ", req.Name, count, total))
 			return models.ErrMissingRequirement
 		}
 	case models.Pick:
 		if req.Minimum != nil {
 			if count < *req.Minimum {
-				log.CErrorf(ctx, "Required requirement %s wasn't satisfied: only %d, less than %d desired, satisfy the condition", req.Name, count, req.Minimum)
-				result.Errors = append(result.Errors, fmt.Sprintf("Required requirement %s wasn't satisfied: only %d, less than %d desired, satisfy the condition", req.Name, count, req.Minimum))
+				log.CErrorf(ctx, "Required requirement %s wasn't satisfied: only %d, less than %d desired, satisfy the condition", req.Name, count, *req.Minimum)
+				result.Errors = append(result.Errors, fmt.Sprintf("Required requirement %s wasn't satisfied: only %d, less than %d desired, satisfy the condition", req.Name, count, *req.Minimum))
 				return models.ErrMissingRequirement
 			}
 		}
 		if req.Maximum != nil {
 			if count > *req.Maximum {
-				log.CErrorf(ctx, "Required requirement %s wasn't satisfied:  %d, more than of %d maximum, satisfy the condition", req.Name, count, req.Maximum)
-				result.Errors = append(result.Errors, fmt.Sprintf("Required requirement %s wasn't satisfied:  %d, more than of %d maximum, satisfy the condition", req.Name, count, req.Maximum))
+				log.CErrorf(ctx, "Required requirement %s wasn't satisfied:  %d, more than of %d maximum, satisfy the condition", req.Name, count, *req.Maximum)
+				result.Errors = append(result.Errors, fmt.Sprintf("Required requirement %s wasn't satisfied:  %d, more than of %d maximum, satisfy the condition", req.Name, count, *req.Maximum))
 				return models.ErrMissingRequirement
 			}
 		}
 		if req.Count != nil {
 			if count != *req.Count {
-				log.CErrorf(ctx, "Required requirement %s wasn't satisfied: only %d instead of %d desired satisfy the condition", req.Name, count, req.Count)
-				result.Errors = append(result.Errors, fmt.Sprintf("Required requirement %s wasn't satisfied: only %d instead of %d desired satisfy the condition", req.Name, count, req.Count))
+				log.CErrorf(ctx, "Required requirement %s wasn't satisfied: only %d instead of %d desired satisfy the condition", req.Name, count, *req.Count)
+				result.Errors = append(result.Errors, fmt.Sprintf("Required requirement %s wasn't satisfied: only %d instead of %d desired satisfy the condition", req.Name, count, *req.Count))
 				return models.ErrMissingRequirement
 			}
 		}
@@ -555,7 +1346,198 @@ func (vs *ValidatorServiceDIF) validateRequirement(ctx echo.Context, result *mod
 	return nil
 }
 
-func (vs *ValidatorServiceDIF) verifyStatus(ctx echo.Context, result *models.VerificationResult, cred *models.VerifiableCredential) error {
+func (vs *ValidatorServiceDIF) now() time.Time {
+	if vs.clock == nil {
+		return time.Now()
+	}
+	return vs.clock()
+}
+
+// validateValidity checks that the credential and its proofs aren't dated in the future, beyond the clock skew
+func (vs *ValidatorServiceDIF) validateValidity(ctx echo.Context, result *models.VerificationResult, vc *models.VerifiableCredential) error {
+	limit := vs.now().Add(vs.clockSkew)
+	for _, date := range []*models.TimeWithFormat{vc.IssuanceDate, vc.ValidFrom} {
+		if date != nil && date.After(limit) {
+			log.CErrorf(ctx, "Credential %s is not valid until %s", vc.Id, date.Time.Format(time.RFC3339))
+			result.Errors = append(result.Errors, fmt.Sprintf("Credential %s is not valid until %s", vc.Id, date.Time.Format(time.RFC3339)))
+			return models.ErrCredentialNotYetValid
+		}
+	}
+	if vc.GetProofs() == nil || vc.GetProofs().GetProof() == nil {
+		return nil
+	}
+	for _, proof := range *vc.GetProofs().GetProof() {
+		if proof.Created != nil && proof.Created.After(limit) {
+			log.CErrorf(ctx, "Proof of credential %s created in the future %s", vc.Id, proof.Created.Time.Format(time.RFC3339))
+			result.Errors = append(result.Errors, fmt.Sprintf("Proof of credential %s is created in the future", vc.Id))
+			return models.ErrCredentialNotYetValid
+		}
+	}
+	return nil
+}
+
+// validateBinding checks that every proof of the presentation carries the challenge and domain of the definition,
+// so a presentation made for another exchange or verifier is refused. Second-factor proofs are bound on their own.
+func validateBinding(ctx echo.Context, result *models.VerificationResult, pd *models.PresentationDefinition, vp *models.VerifiablePresentation, factors []*regexp.Regexp) error {
+	if pd.Challenge == "" {
+		return nil
+	}
+	bound := false
+	if vp.GetProofs() != nil && vp.GetProofs().GetProof() != nil {
+		proofs := *vp.GetProofs().GetProof()
+		for i := range proofs {
+			proof := &proofs[i]
+			if isFactorProof(proof, factors) {
+				continue
+			}
+			if proof.Challenge != pd.Challenge {
+				log.CErrorf(ctx, "Presentation proof %s is not bound to the challenge of definition %s", proof.GetCreator(), pd.ID)
+				result.Errors = append(result.Errors, "Presentation proof is not bound to the challenge of the exchange")
+				return models.ErrInvalidChallenge
+			}
+			if pd.Domain != "" && !boundToDomain(proof, pd.Domain) {
+				log.CErrorf(ctx, "Presentation proof %s is not bound to the domain %s", proof.GetCreator(), pd.Domain)
+				result.Errors = append(result.Errors, "Presentation proof is not bound to the domain of the verifier")
+				return models.ErrInvalidDomain
+			}
+			bound = true
+		}
+	}
+	if !bound {
+		log.CErrorf(ctx, "Presentation has no proof bound to the challenge of definition %s", pd.ID)
+		result.Errors = append(result.Errors, "Presentation proof is not bound to the challenge of the exchange")
+		return models.ErrInvalidChallenge
+	}
+	result.Checks = append(result.Checks, CheckChallenge)
+	return nil
+}
+
+// boundToDomain tells whether the proof is bound to the domain, which JWT proofs state as one of their audiences
+func boundToDomain(proof *models.Proof, domain string) bool {
+	if proof.Type != models.ProofTypeJWT || proof.Jws == "" {
+		return proof.Domain == domain
+	}
+	jws, err := models.ParseJWS(proof.Jws)
+	if err != nil {
+		return false
+	}
+	return jws.HasAudience(domain)
+}
+
+// validatePresentationValidity checks that the presentation is within its validity period and that its proofs
+// aren't created in the future, beyond the clock skew
+func (vs *ValidatorServiceDIF) validatePresentationValidity(ctx echo.Context, result *models.VerificationResult, vp *models.VerifiablePresentation) error {
+	now := vs.now()
+	if vp.ExpirationDate != nil && vp.ExpirationDate.Before(now.Add(-vs.clockSkew)) {
+		log.CErrorf(ctx, "Presentation expired on %s", vp.ExpirationDate.Time.Format(time.RFC3339))
+		result.Errors = append(result.Errors, fmt.Sprintf("Presentation expired on %s", vp.ExpirationDate.Time.Format(time.RFC3339)))
+		return models.ErrPresentationExpired
+	}
+	limit := now.Add(vs.clockSkew)
+	if vp.IssuanceDate != nil && vp.IssuanceDate.After(limit) {
+		log.CErrorf(ctx, "Presentation is not valid until %s", vp.IssuanceDate.Time.Format(time.RFC3339))
+		result.Errors = append(result.Errors, fmt.Sprintf("Presentation is not valid until %s", vp.IssuanceDate.Time.Format(time.RFC3339)))
+		return models.ErrPresentationNotYetValid
+	}
+	if vp.GetProofs() == nil || vp.GetProofs().GetProof() == nil {
+		return nil
+	}
+	for _, proof := range *vp.GetProofs().GetProof() {
+		if proof.Created != nil && proof.Created.After(limit) {
+			log.CErrorf(ctx, "Proof of presentation created in the future %s", proof.Created.Time.Format(time.RFC3339))
+			result.Errors = append(result.Errors, "Proof of presentation is created in the future")
+			return models.ErrPresentationNotYetValid
+		}
+	}
+	return nil
+}
+
+// validateExpiry checks that the credential hasn't expired, taking into account the clock skew
+func (vs *ValidatorServiceDIF) validateExpiry(ctx echo.Context, result *models.VerificationResult, vc *models.VerifiableCredential) error {
+	if vc.ExpirationDate != nil && vc.ExpirationDate.Before(vs.now().Add(-vs.clockSkew)) {
+		log.CErrorf(ctx, "Credential %s expired on %s", vc.Id, vc.ExpirationDate.Time.Format(time.RFC3339))
+		result.Errors = append(result.Errors, fmt.Sprintf("Credential %s expired on %s", vc.Id, vc.ExpirationDate.Time.Format(time.RFC3339)))
+		return models.ErrCredentialExpired
+	}
+	return nil
+}
+
+// validateAssurance checks that the evidence of the credential reaches the level of assurance required by the descriptor.
+// Expired identity documents lower the level, so the evidence is evaluated at the current time.
+func (vs *ValidatorServiceDIF) validateAssurance(ctx echo.Context, result *models.VerificationResult, vc *models.VerifiableCredential, required models.LevelOfAssurance) error {
+	if required == "" {
+		return nil
+	}
+	level := vc.Evidence.LevelOfAssurance(vs.now())
+	if !level.Satisfies(required) {
+		log.CErrorf(ctx, "Evidence of credential %s reaches level of assurance %q instead of %s", vc.Id, level, required)
+		result.Errors = append(result.Errors, fmt.Sprintf("Evidence of credential %s doesn't reach the %s level of assurance", vc.Id, required))
+		return models.ErrInsufficientAssurance
+	}
+	result.Checks = append(result.Checks, CheckAssurance)
+	return nil
+}
+
+// validateIssuerTrust checks that the issuer is accredited by the governance framework for the schema of the credential,
+// with the level of trust required by the descriptor. The framework is queried on every submission, so issuers removed
+// from it stop being accepted right away, even by the exchanges already open.
+func (vs *ValidatorServiceDIF) validateIssuerTrust(ctx echo.Context, result *models.VerificationResult, vc *models.VerifiableCredential, descriptor *models.InputDescriptor) error {
+	if descriptor.TrustLevel <= 0 {
+		return nil
+	}
+	if vs.govS == nil {
+		log.CErrorf(ctx, "No governance service available to check the trust of issuer %s", vc.Issuer)
+		result.Errors = append(result.Errors, fmt.Sprintf("Cannot check the trust of issuer %s", vc.Issuer))
+		return models.ErrUntrustedIssuer
+	}
+	schemas := vs.credentialSchemas(vc, descriptor)
+	issuers, err := vs.govS.GetTrustedIssuersForSchemas(ctx, credentialType(vc), schemas)
+	if err != nil {
+		log.CErrorf(ctx, "Cannot retrieve the issuers trusted for credential %s: %v", vc.Id, err)
+		result.Errors = append(result.Errors, fmt.Sprintf("Cannot check the trust of issuer %s", vc.Issuer))
+		return err
+	}
+	now := vs.now()
+	for _, issuer := range issuers {
+		if issuer.HasDID(vc.Issuer) && issuer.Accreditations.Accredits(descriptor.TrustLevel, schemas, now) {
+			result.Checks = append(result.Checks, CheckTrust)
+			return nil
+		}
+	}
+	log.CErrorf(ctx, "Issuer %s is not accredited with level of trust %d for credential %s", vc.Issuer, descriptor.TrustLevel, vc.Id)
+	result.Errors = append(result.Errors, fmt.Sprintf("Issuer %s is not accredited with level of trust %d for credential %s", vc.Issuer, descriptor.TrustLevel, vc.Id))
+	return models.ErrUntrustedIssuer
+}
+
+// credentialType returns the most specific type of the credential, which is listed last
+func credentialType(vc *models.VerifiableCredential) string {
+	if len(vc.Type) == 0 {
+		return ""
+	}
+	return vc.Type[len(vc.Type)-1]
+}
+
+// credentialSchemas returns the schemas an accreditation may refer to: the ones requested by the descriptor that the
+// credential matches, as checked by validateSchemas. The schemas and contexts the credential declares on its own don't count.
+func (vs *ValidatorServiceDIF) credentialSchemas(vc *models.VerifiableCredential, descriptor *models.InputDescriptor) []string {
+	schemas := []string{}
+	for _, schema := range descriptor.Schema {
+		var matches bool
+		if vc.CredentialSchema != nil {
+			matches = schema.URI == vc.CredentialSchema.Id
+		} else if vc.Context != nil {
+			matches = tools.Contains(vc.Context.GetContext(), schema.URI)
+		} else {
+			matches = vs.jVal.ValidateWithRef(vc, schema.URI) == nil
+		}
+		if matches {
+			schemas = append(schemas, schema.URI)
+		}
+	}
+	return tools.UniqueSlice(schemas)
+}
+
+func (vs *ValidatorServiceDIF) verifyStatus(ctx echo.Context, result *models.VerificationResult, cred *models.VerifiableCredential, requesterVMethod string) error {
 	status := cred.CredentialStatus
 	if status == nil {
 		log.CDebug(ctx, "Credential has no Status")
@@ -563,78 +1545,236 @@ func (vs *ValidatorServiceDIF) verifyStatus(ctx echo.Context, result *models.Ver
 		return nil
 	}
 
-	req, err := http.NewRequest("GET", status.Id, nil)
+	resolver, err := vs.getStatusResolver(status.Type)
 	if err != nil {
-		log.CErrorf(ctx, "Error creating service request to query status %s. Error: %v", status.Id, err)
+		log.CErrorf(ctx, "Credential status %s of type %s is not supported", status.Id, status.Type)
 		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(log.HeaderXSpanId, log.GetTraceId(ctx))
-
-	//Setting timeout as context on the request to prevent slow response on offline/block hosts
-	client := &http.Client{Timeout: thresholdVCStatusCheck}
-
-	res, err := client.Do(req)
+	currentStatus, err := resolver.ResolveStatus(ctx, cred, requesterVMethod)
 	if err != nil {
-		log.CErrorf(ctx, "Error requesting credential status %s. Error: %v", status.Id, err)
+		log.CErrorf(ctx, "Error resolving credential status %s of type %s. Error: %v", status.Id, status.Type, err)
 		return err
-	} else if res.StatusCode != 200 {
-		log.CErrorf(ctx, "Error requesting credential status. Status code not success", status.Id)
-		return models.ErrStatusNotValid
 	}
+	if currentStatus == constant.CredentialStatusIssued {
+		log.CDebugf(ctx, "[Validation Status] Status validated")
+		return nil
+	}
+	statusErr := models.GetStatusError(currentStatus)
+	if vs.policy.IsStatusTolerated(currentStatus) {
+		log.CWarnf(ctx, "[Validation Status] Status %s tolerated by policy", currentStatus)
+		result.Warnings = append(result.Warnings, fmt.Sprintf("Credential %s accepted with status %s: %s", cred.Id, currentStatus, statusErr.Error()))
+		return nil
+	}
+	log.CDebugf(ctx, "[Validation Status] Status not validated. %s", currentStatus)
+	return statusErr
+}
 
-	vst := models.VerifiableStatus{}
-	defer res.Body.Close()
-	err = json.NewDecoder(res.Body).Decode(&vst)
+// proveMechanisms checks the descriptors satisfied by the proofs of the presentation instead of by credentials, like
+// second factors, returning whether each one is proved. Every field of the descriptor must be proved by a proof whose
+// key matches its pattern. The key id stated by a proof can be forged, so the proof must come with a signature of it.
+func (vs *ValidatorServiceDIF) proveMechanisms(ctx echo.Context, pd *models.PresentationDefinition, resp *models.VerifiablePresentation) map[string]error {
+	proofs := []models.Proof{}
+	if resp.GetProofs() != nil && resp.GetProofs().GetProof() != nil {
+		proofs = *resp.GetProofs().GetProof()
+	}
+	proved := map[string]error{}
+	patterns := map[string][]*regexp.Regexp{}
+	factors := []*regexp.Regexp{}
+	for _, id := range pd.InputDescriptors {
+		if id.GetMechanism() == "" {
+			continue
+		}
+		compiled, err := proofPatterns(&id)
+		if err != nil {
+			log.CErrorf(ctx, "Descriptor %s doesn't state the proofs satisfying it", id.ID)
+			proved[id.ID] = models.ErrMissingSecondFactor
+			continue
+		}
+		patterns[id.ID] = compiled
+		factors = append(factors, compiled...)
+	}
+	holders := presentationHolders(resp, factors)
+	for i := range pd.InputDescriptors {
+		descriptor := &pd.InputDescriptors[i]
+		compiled, ok := patterns[descriptor.ID]
+		if !ok {
+			continue
+		}
+		proved[descriptor.ID] = nil
+		for _, pattern := range compiled {
+			err := vs.proveField(ctx, pd, descriptor, pattern, proofs, holders)
+			if err != nil {
+				log.CErrorf(ctx, "Descriptor %s requires a proof of %s: %v", descriptor.ID, pattern.String(), err)
+				proved[descriptor.ID] = err
+				break
+			}
+		}
+	}
+	return proved
+}
+
+// proveField looks for a proof of the presentation, signed with a key matching the pattern, that can be verified
+func (vs *ValidatorServiceDIF) proveField(ctx echo.Context, pd *models.PresentationDefinition, descriptor *models.InputDescriptor, pattern *regexp.Regexp, proofs []models.Proof, holders []string) error {
+	err := models.ErrMissingSecondFactor
+	for i := range proofs {
+		if !pattern.MatchString(proofs[i].GetCreator()) {
+			continue
+		}
+		err = vs.verifySecondFactorProof(ctx, pd, descriptor, &proofs[i], holders)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// verifySecondFactorProof checks the proof of a second factor: a JWS signed with the key of the factor, whose nonce is
+// the challenge of the exchange, whose aud is its domain and whose iat is recent enough. Descriptors with a holder
+// directive also require the key of the factor to belong to the holder of the presentation.
+func (vs *ValidatorServiceDIF) verifySecondFactorProof(ctx echo.Context, pd *models.PresentationDefinition, descriptor *models.InputDescriptor, proof *models.Proof, holders []string) error {
+	creator := proof.GetCreator()
+	if proof.Jws == "" {
+		log.CErrorf(ctx, "Second factor %s has no signature", creator)
+		return models.ErrMissingSFProof
+	}
+	jws, err := models.ParseJWS(proof.Jws)
 	if err != nil {
-		log.CErrorf(ctx, "Error decoding requesting credential status %s. Error: %v", status.Id, err)
-		return err
+		log.CErrorf(ctx, "Cannot decode the signature of second factor %s", creator)
+		return models.ErrMissingSFProof
+	}
+	if pd.Challenge == "" {
+		log.CErrorf(ctx, "Definition %s has no challenge to bind second factor %s", pd.ID, creator)
+		return models.ErrSFValidation
+	}
+	if jws.StringClaim("nonce") != pd.Challenge {
+		log.CErrorf(ctx, "Second factor %s is not bound to the challenge of the exchange", creator)
+		return models.ErrSFValidation
+	}
+	if pd.Domain != "" && !jws.HasAudience(pd.Domain) {
+		log.CErrorf(ctx, "Second factor %s is not bound to the domain %s", creator, pd.Domain)
+		return models.ErrSFValidation
+	}
+	now := vs.now()
+	issued := jws.DateClaim("iat")
+	if issued == nil || issued.After(now.Add(vs.clockSkew)) || issued.Before(now.Add(-vs.secondFactorAge-vs.clockSkew)) {
+		log.CErrorf(ctx, "Second factor %s is not fresh, issued at %v", creator, issued)
+		return models.ErrSFValidation
+	}
+	if directive := descriptor.Constraints.HolderDirective(); directive != nil && *directive == models.Required {
+		if !tools.Contains(holders, strings.Split(creator, "#")[0]) {
+			log.CErrorf(ctx, "Second factor %s doesn't belong to the holder %v", creator, holders)
+			return models.ErrSFValidation
+		}
+	}
+	//The signature is verified with the key of the factor, as found in the DID document it belongs to
+	factorProof := *proof
+	factorProof.VerificationMethod = creator
+	if err = vs.verifyJWS(ctx, &factorProof, strings.Split(creator, "#")[0], proofPurposeAuthentication); err != nil {
+		return models.ErrSFValidation
+	}
+	return nil
+}
+
+// proofPatterns compiles the patterns of the fields of a descriptor satisfied by the proofs of the presentation
+func proofPatterns(descriptor *models.InputDescriptor) ([]*regexp.Regexp, error) {
+	sources := descriptor.ProofPatterns()
+	if len(sources) == 0 {
+		return nil, models.ErrMissingSecondFactor
+	}
+	compiled := []*regexp.Regexp{}
+	for _, source := range sources {
+		pattern, err := compilePattern(source)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, pattern)
 	}
+	return compiled, nil
+}
 
-	for _, c := range vst.VerifiableCredential {
-		if c.Claim.Id == cred.Id {
-			if c.Claim.CurrentStatus == constant.CredentialStatusIssued {
-				log.CDebugf(ctx, "[Validation Status] Status validated")
-				return nil
-			}
-			log.CDebugf(ctx, "[Validation Status] Status not validated. %s", c.Claim.CurrentStatus)
-			return models.ErrStatusNotValid
+// factorPatterns compiles the patterns of the keys proving the mechanisms of a definition, like second factors.
+// Their proofs come along with the proof of the presentation without being one.
+func factorPatterns(pd *models.PresentationDefinition) []*regexp.Regexp {
+	factors := []*regexp.Regexp{}
+	for i := range pd.InputDescriptors {
+		if pd.InputDescriptors[i].GetMechanism() == "" {
+			continue
+		}
+		if compiled, err := proofPatterns(&pd.InputDescriptors[i]); err == nil {
+			factors = append(factors, compiled...)
 		}
 	}
+	return factors
+}
 
-	log.CWarnf(ctx, "[Validation Status] Credential status scanned but not found")
+// isFactorProof tells if a proof is signed by the key of a second factor
+func isFactorProof(proof *models.Proof, factors []*regexp.Regexp) bool {
+	for _, pattern := range factors {
+		if pattern.MatchString(proof.GetCreator()) {
+			return true
+		}
+	}
+	return false
+}
 
-	return models.ErrStatusNotValid
+// presentationProof returns the proof a presentation is verified with: its JWT, if received as one, or else its first
+// linked data proof. Proofs of second factors, signed by keys matching factors, are left out.
+func presentationProof(vp *models.VerifiablePresentation, factors []*regexp.Regexp) *models.Proof {
+	if proof := findJWTProof(vp.GetProofs(), factors...); proof != nil {
+		return proof
+	}
+	return findLinkedDataProof(vp.GetProofs())
 }
 
-func (vs *ValidatorServiceDIF) validateSecondFactors(ctx echo.Context, result *models.VerificationResult, pd *models.PresentationDefinition, resp *models.VerifiablePresentation) error {
-	for _, id := range pd.InputDescriptors {
-		if tools.Contains(id.Group, "identity") {
-			expectedKey := regexp.MustCompile(id.Constraints.Fields[0].Filter.Pattern)
-			found := false
-			for _, p := range *resp.GetProofs().GetProof() {
-				if len(expectedKey.Find([]byte(p.GetCreator()))) > 0 {
-					found = true
-					break
-				}
+// presentationHolders returns the DID holding a presentation: the signer of the presentation proof, the same one
+// verifyPresentationProof verifies, so the validation fails when it is forged. The holder stated by the presentation
+// isn't trusted on its own.
+func presentationHolders(vp *models.VerifiablePresentation, factors []*regexp.Regexp) []string {
+	proof := presentationProof(vp, factors)
+	if proof == nil || proof.GetCreator() == "" {
+		return []string{}
+	}
+	return []string{strings.Split(proof.GetCreator(), "#")[0]}
+}
+
+// validateProofFormat checks that every proof uses a suite, or a JWT algorithm, accepted by the format. Proofs of
+// second factors, signed by keys matching factors, follow their own format. It returns the suite rejected, so it can
+// be reported.
+func validateProofFormat(ctx echo.Context, proofs *models.SSIProof, allows func(proofType, alg string) bool, factors []*regexp.Regexp) (string, error) {
+	var list []models.Proof
+	if proofs != nil && proofs.GetProof() != nil {
+		for _, p := range *proofs.GetProof() {
+			if !isFactorProof(&p, factors) {
+				list = append(list, p)
 			}
-			if !found {
-				log.CError(ctx, "Required signing key not found in validation", id.Constraints.Fields[0].Filter.Pattern)
-				err := models.ErrMissingSecondFactor
-				result.Errors = append(result.Errors, err.Error())
-				return err
+		}
+	}
+	if len(list) == 0 && !allows("", "") {
+		log.CError(ctx, "Missing proof required by the definition format")
+		return "none", models.ErrUnsupportedFormat
+	}
+	for _, p := range list {
+		suite, alg := p.Type, ""
+		if p.Type == models.ProofTypeJWT {
+			if jws, err := models.ParseJWS(p.Jws); err == nil {
+				alg = jws.Header.Alg
 			}
+			suite = fmt.Sprintf("%s (%s)", p.Type, alg)
+		}
+		if !allows(p.Type, alg) {
+			log.CErrorf(ctx, "Proof %s is not accepted by the definition format", suite)
+			return suite, models.ErrUnsupportedFormat
 		}
 	}
-	return nil
+	return "", nil
 }
 
-//Filtering functions
+// Filtering functions
 func findIssuerInProofs(ctx echo.Context, vc *models.VerifiableCredential, expectedIssuer string) error {
 	proofs := vc.GetProofs()
-	if proofs != nil {
+	if expectedIssuer != "" && proofs != nil && proofs.GetProof() != nil {
 		for _, p := range *proofs.GetProof() {
-			if strings.Contains(p.GetCreator(), expectedIssuer) {
+			if strings.Split(p.GetCreator(), "#")[0] == expectedIssuer {
 				return nil
 			}
 		}
@@ -667,7 +1807,7 @@ func findInputDescriptorWithId(descs []models.InputDescriptor, id string) *model
 func findInPaths(ctx echo.Context, object map[string]interface{}, paths []string) []interface{} {
 	foundData := []interface{}{}
 	for _, pathDef := range paths {
-		found, err := jsonPath(ctx, pathDef, object)
+		found, err := jsonPath(pathDef, object)
 		if err != nil {
 			log.CWarnf(ctx, "Nothing found in %s: %s", pathDef, err.Error())
 		}
@@ -678,15 +1818,21 @@ func findInPaths(ctx echo.Context, object map[string]interface{}, paths []string
 	return foundData
 }
 
-func jsonPath(ctx echo.Context, path string, input interface{}) ([]interface{}, error) {
-	mappedData, err := tools.ToMap(input)
+// jsonPath evaluates a path on a generic tree, as decoded from JSON, so the data isn't encoded again for every path
+func jsonPath(path string, tree interface{}) ([]interface{}, error) {
+	x, err := compilePath(path)
 	if err != nil {
-		log.CError(ctx, "Cannot convert vc to map to process it")
 		return nil, err
 	}
-	x, err := jp.ParseString(path)
-	ys := x.Get(mappedData)
-	return ys, nil
+	return x.Get(tree), nil
+}
+
+// requestContext returns the context of the ongoing request, so the work made for it can be cancelled when it ends
+func requestContext(ctx echo.Context) context.Context {
+	if ctx == nil || ctx.Request() == nil {
+		return context.Background()
+	}
+	return ctx.Request().Context()
 }
 
 func normalizeResult(result *models.VerificationResult) *models.VerificationResult {
@@ -1,9 +1,22 @@
 package service
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/gataca-io/vui-core/constant"
 	"github.com/gataca-io/vui-core/log"
 	"github.com/gataca-io/vui-core/models"
 	"github.com/gataca-io/vui-core/testdata"
@@ -19,6 +32,16 @@ type mockSSIService struct{}
 
 type mockJSONValidator struct{}
 
+type mockSlowSSIService struct {
+	mockSSIService
+	delays map[string]time.Duration
+	fails  map[string]error
+}
+
+type mockDidService struct {
+	docs map[string]*models.DIDDocument
+}
+
 func (ms *mockSSIService) ValidateLdContext(ctx echo.Context, ldv models.LdContext) (string, error) {
 	return "", nil
 }
@@ -66,6 +89,35 @@ func (mj *mockJSONValidator) ValidateStrings(schema, document string) error {
 	return nil
 }
 
+func (ms *mockSlowSSIService) VerifyCredential(ctx echo.Context, vc *models.VerifiableCredential, requester string, sbx bool) (int, error) {
+	time.Sleep(ms.delays[vc.Id])
+	return 0, ms.fails[vc.Id]
+}
+
+func (md *mockDidService) GetDID(ctx echo.Context, did string) (*models.DIDDocument, error) {
+	if doc, ok := md.docs[did]; ok {
+		return doc, nil
+	}
+	return nil, models.ErrNotFound
+}
+func (md *mockDidService) CreateDID(ctx echo.Context, did *models.DIDDocument) error {
+	return nil
+}
+func (md *mockDidService) UpdateDID(ctx echo.Context, did *models.DIDDocument) error {
+	return nil
+}
+type mockGovernanceService struct {
+	issuers []models.TrustedIssuer
+}
+
+func (mg *mockGovernanceService) GetTrustedIssuersForSchemas(ctx echo.Context, credentialType string, schemasOrContexts []string) ([]models.TrustedIssuer, error) {
+	return mg.issuers, nil
+}
+
+func (md *mockDidService) RevokeDID(ctx echo.Context, did *models.DIDDocument) error {
+	return nil
+}
+
 func init() {
 	mockedSSIs = &mockSSIService{}
 	mockedJVal = &mockJSONValidator{}
@@ -105,9 +157,8 @@ func TestDIFValidatorService_ValidateSameSubject(t *testing.T) {
 	vp := createVerifiablePresentation(t)
 	res := createEmptyVerificationResult()
 
-	subj, err := difValidator.getCredentialSubject(nil, res, vp.VerifiableCredential)
-	assert.NoError(t, err)
-	assert.Equal(t, "did:example:ebfeb1f712ebc6f1c276e12ec21", subj)
+	subjects := difValidator.getCredentialSubjects(nil, res, vp.VerifiableCredential)
+	assert.Equal(t, []string{"did:example:ebfeb1f712ebc6f1c276e12ec21"}, subjects)
 	assert.NotEmpty(t, res.Checks)
 	assert.Equal(t, 1, len(res.Checks))
 	assert.Empty(t, res.Errors)
@@ -118,12 +169,37 @@ func TestDIFValidatorService_ValidateDifferentSubject(t *testing.T) {
 	(*vp.VerifiableCredential[0].CredentialSubject)["id"] = "did:example:212444"
 	res := createEmptyVerificationResult()
 
-	subj, err := difValidator.getCredentialSubject(nil, res, vp.VerifiableCredential)
-	assert.Error(t, err)
-	assert.Equal(t, "", subj)
+	subjects := difValidator.getCredentialSubjects(nil, res, vp.VerifiableCredential)
+	assert.Equal(t, []string{"did:example:212444", "did:example:ebfeb1f712ebc6f1c276e12ec21"}, subjects)
 	assert.Empty(t, res.Checks)
+	assert.Empty(t, res.Errors)
+}
+
+func TestDIFValidatorService_ValidateMultiSubject(t *testing.T) {
+	vp := createVerifiablePresentation(t)
+	(*vp.VerifiableCredential[1].CredentialSubject)["id"] = "did:example:212444" //Credential of a minor presented by the guardian
+
+	res, err := difValidator.ValidatePresentationResponse(nil, createPresentationDefinition(t), vp, "")
+	assert.NoError(t, err)
+	assert.Empty(t, res.Errors)
+	assert.NotContains(t, res.Checks, CheckSubject)
+
+	res, err = difValidator.ValidatePresentationResponse(nil, createV2PresentationDefinition(t, models.Required), vp, "")
+	assert.Equal(t, models.ErrSubjectMismatch, err)
+	assert.NotContains(t, res.Checks, CheckSameSubject)
+}
+
+func TestDIFValidatorService_ValidateSubjectIsIssuerWithoutSubject(t *testing.T) {
+	vc := createVerifiablePresentation(t).VerifiableCredential[0]
+	vc.CredentialSubject = nil
+	required := models.Required
+	constraints := &models.Constraints{SubjectIsIssuer: &required}
+
+	res := createEmptyVerificationResult()
+	report := models.NewDescriptorReport("banking_input_2", vc.Id, "$.verifiableCredential[0]")
+	err := difValidator.validateCredentialConstraints(nil, res, report, &vc, nil, constraints)
+	assert.Equal(t, models.ErrHolderBinding, err)
 	assert.NotEmpty(t, res.Errors)
-	assert.Equal(t, 1, len(res.Errors))
 }
 
 func TestDIFValidatorService_ValidateIds(t *testing.T) {
@@ -155,10 +231,10 @@ func TestDIFValidatorService_ValidateSubmission(t *testing.T) {
 	vp := createVerifiablePresentation(t)
 	res := createEmptyVerificationResult()
 
-	err := difValidator.validateSubmission(nil, res, presentationDefinition.InputDescriptors, vp, "")
+	err := difValidator.validateSubmission(nil, res, presentationDefinition, vp, "")
 	assert.NoError(t, err)
 	assert.NotEmpty(t, res.Checks)
-	assert.Equal(t, 16, len(res.Checks))
+	assert.Equal(t, 25, len(res.Checks))
 	assert.Empty(t, res.Errors)
 }
 
@@ -184,7 +260,7 @@ func TestDIFValidatorService_ValidateIssuerSigning(t *testing.T) {
 	assert.Equal(t, models.ErrMissingConstraint, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.NotEmpty(t, res.Errors)
-	assert.Equal(t, 3, len(res.Checks))
+	assert.Equal(t, 4, len(res.Checks))
 	assert.Equal(t, 2, len(res.Errors))
 
 }
@@ -199,7 +275,7 @@ func TestDIFValidatorService_ValidateIssuerPatternConstraint(t *testing.T) {
 	assert.Equal(t, models.ErrMissingConstraint, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.NotEmpty(t, res.Errors)
-	assert.Equal(t, 3, len(res.Checks))
+	assert.Equal(t, 4, len(res.Checks))
 	assert.Equal(t, 2, len(res.Errors))
 }
 
@@ -216,7 +292,7 @@ func TestDIFValidatorService_ValidateAccountIdMinLengthConstraint(t *testing.T)
 	assert.Equal(t, models.ErrMissingConstraint, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.NotEmpty(t, res.Errors)
-	assert.Equal(t, 6, len(res.Checks))
+	assert.Equal(t, 9, len(res.Checks))
 	assert.Equal(t, 2, len(res.Errors))
 }
 
@@ -233,7 +309,7 @@ func TestDIFValidatorService_ValidateAccountIdMaxLengthConstraint(t *testing.T)
 	assert.Equal(t, models.ErrMissingConstraint, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.NotEmpty(t, res.Errors)
-	assert.Equal(t, 6, len(res.Checks))
+	assert.Equal(t, 9, len(res.Checks))
 	assert.Equal(t, 2, len(res.Errors))
 }
 
@@ -250,7 +326,7 @@ func TestDIFValidatorService_ValidateAccountIdPatternConstraintValid(t *testing.
 	assert.NoError(t, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.Empty(t, res.Errors)
-	assert.Equal(t, 10, len(res.Checks))
+	assert.Equal(t, 13, len(res.Checks))
 }
 
 func TestDIFValidatorService_ValidateAccountIdPatternConstraintInvalid(t *testing.T) {
@@ -266,7 +342,7 @@ func TestDIFValidatorService_ValidateAccountIdPatternConstraintInvalid(t *testin
 	assert.Equal(t, models.ErrMissingConstraint, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.NotEmpty(t, res.Errors)
-	assert.Equal(t, 6, len(res.Checks))
+	assert.Equal(t, 9, len(res.Checks))
 	assert.Equal(t, 2, len(res.Errors))
 }
 
@@ -281,7 +357,7 @@ func TestDIFValidatorService_ValidatePreferred(t *testing.T) {
 	assert.Equal(t, models.ErrMissingConstraint, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.NotEmpty(t, res.Errors)
-	assert.Equal(t, 7, len(res.Checks))
+	assert.Equal(t, 10, len(res.Checks))
 	assert.Equal(t, 2, len(res.Errors))
 }
 
@@ -299,7 +375,7 @@ func TestDIFValidatorService_ValidateAccountBooleanPattern(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.Empty(t, res.Errors)
-	assert.Equal(t, 10, len(res.Checks))
+	assert.Equal(t, 13, len(res.Checks))
 }
 
 func TestDIFValidatorService_ValidateMissingField(t *testing.T) {
@@ -314,7 +390,7 @@ func TestDIFValidatorService_ValidateMissingField(t *testing.T) {
 	assert.Equal(t, models.ErrMissingConstraint, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.NotEmpty(t, res.Errors)
-	assert.Equal(t, 7, len(res.Checks))
+	assert.Equal(t, 10, len(res.Checks))
 	assert.Equal(t, 2, len(res.Errors))
 }
 
@@ -330,7 +406,7 @@ func TestDIFValidatorService_ValidateDateConstraint(t *testing.T) {
 	assert.Equal(t, models.ErrMissingConstraint, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.NotEmpty(t, res.Errors)
-	assert.Equal(t, 7, len(res.Checks))
+	assert.Equal(t, 10, len(res.Checks))
 	assert.Equal(t, 2, len(res.Errors))
 }
 
@@ -345,7 +421,7 @@ func TestDIFValidatorService_ValidateNoSchema(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.Empty(t, res.Errors)
-	assert.Equal(t, 10, len(res.Checks))
+	assert.Equal(t, 13, len(res.Checks))
 }
 
 func TestDIFValidatorService_ValidateContextSchema(t *testing.T) {
@@ -359,7 +435,7 @@ func TestDIFValidatorService_ValidateContextSchema(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.Empty(t, res.Errors)
-	assert.Equal(t, 10, len(res.Checks))
+	assert.Equal(t, 13, len(res.Checks))
 }
 
 func TestDIFValidatorService_ValidateWrongSchema(t *testing.T) {
@@ -389,7 +465,7 @@ func TestDIFValidatorService_ValidatePossibleSchema(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.Empty(t, res.Errors)
-	assert.Equal(t, 10, len(res.Checks))
+	assert.Equal(t, 13, len(res.Checks))
 }
 
 func TestDIFValidatorService_ValidateRequiredSchema(t *testing.T) {
@@ -417,7 +493,7 @@ func TestDIFValidatorService_ValidateSubmissionRequirementsCount(t *testing.T) {
 	assert.Equal(t, models.ErrMissingRequirement, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.NotEmpty(t, res.Errors)
-	assert.Equal(t, 8, len(res.Checks))
+	assert.Equal(t, 11, len(res.Checks))
 	assert.Equal(t, 1, len(res.Errors))
 }
 
@@ -432,7 +508,7 @@ func TestDIFValidatorService_ValidateSubmissionRequirementsMin(t *testing.T) {
 	assert.Equal(t, models.ErrMissingRequirement, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.NotEmpty(t, res.Errors)
-	assert.Equal(t, 8, len(res.Checks))
+	assert.Equal(t, 11, len(res.Checks))
 	assert.Equal(t, 1, len(res.Errors))
 }
 
@@ -446,7 +522,7 @@ func TestDIFValidatorService_ValidateSubmissionRequirementsAll(t *testing.T) {
 	assert.Equal(t, models.ErrMissingRequirement, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.NotEmpty(t, res.Errors)
-	assert.Equal(t, 8, len(res.Checks))
+	assert.Equal(t, 11, len(res.Checks))
 	assert.Equal(t, 1, len(res.Errors))
 }
 
@@ -458,5 +534,1018 @@ func TestDIFValidatorService_ValidateComplete(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, res.Checks)
 	assert.Empty(t, res.Errors)
-	assert.Equal(t, 10, len(res.Checks))
+	assert.Equal(t, 13, len(res.Checks))
+}
+
+func TestDIFValidatorService_ValidateLimitDisclosure(t *testing.T) {
+	presentationDefinition := createPresentationDefinition(t)
+	vp := createVerifiablePresentation(t)
+	presentationDefinition.InputDescriptors[1].Constraints.LimitDisclosure = models.NewLegacyDisclosure(true) //All the account claims are requested
+
+	res, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.NoError(t, err)
+	assert.Empty(t, res.Errors)
+	assert.Contains(t, res.Checks, CheckDisclosure)
+}
+
+func TestDIFValidatorService_ValidateLimitDisclosureExceeded(t *testing.T) {
+	presentationDefinition := createPresentationDefinition(t)
+	vp := createVerifiablePresentation(t)
+	presentationDefinition.InputDescriptors[1].Constraints.LimitDisclosure = models.NewLegacyDisclosure(true)
+	subject := *vp.VerifiableCredential[0].CredentialSubject
+	subject["name"] = "John Doe" //Not requested by any field
+
+	res, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.Error(t, err)
+	assert.Equal(t, models.ErrLimitDisclosure, err)
+	assert.NotContains(t, res.Checks, CheckDisclosure)
+	assert.Equal(t, 2, len(res.Errors))
+}
+
+func TestDIFValidatorService_ValidateSubjectIsHolder(t *testing.T) {
+	presentationDefinition := createPresentationDefinition(t)
+	vp := createVerifiablePresentation(t)
+	presentationDefinition.InputDescriptors[1].SetSubjectIsHolder(models.Required) //VP signed with a key of the subject
+
+	res, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.NoError(t, err)
+	assert.Empty(t, res.Errors)
+	assert.Contains(t, res.Checks, CheckHolder)
+}
+
+func TestDIFValidatorService_ValidateSubjectIsNotHolder(t *testing.T) {
+	presentationDefinition := createPresentationDefinition(t)
+	vp := createVerifiablePresentation(t)
+	presentationDefinition.InputDescriptors[1].SetSubjectIsHolder(models.Required)
+	vp.Proof.Value.VerificationMethod = "did:example:attacker#keys-1"
+
+	res, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.Error(t, err)
+	assert.Equal(t, models.ErrHolderBinding, err)
+	assert.NotContains(t, res.Checks, CheckHolder)
+	assert.Equal(t, 2, len(res.Errors))
+}
+
+func TestDIFValidatorService_ValidateSubjectIsHolderForgedProof(t *testing.T) {
+	presentationDefinition := createPresentationDefinition(t)
+	vp := createVerifiablePresentation(t)
+	presentationDefinition.InputDescriptors[1].SetSubjectIsHolder(models.Required)
+	//The presentation is signed by someone else, next to an unsigned proof naming the subject
+	signed := *vp.Proof.Value
+	signed.VerificationMethod = "did:example:attacker#keys-1"
+	forged := models.Proof{Type: signed.Type, Creator: "did:example:ebfeb1f712ebc6f1c276e12ec21#keys-1"}
+	vp.Proof = &models.SSIProof{Values: &[]models.Proof{signed, forged}}
+
+	res, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.Equal(t, models.ErrHolderBinding, err)
+	assert.NotContains(t, res.Checks, CheckHolder)
+}
+
+func TestDIFValidatorService_ValidateSubjectIsHolderPreferred(t *testing.T) {
+	presentationDefinition := createPresentationDefinition(t)
+	vp := createVerifiablePresentation(t)
+	presentationDefinition.InputDescriptors[1].SetSubjectIsHolder(models.Preferred)
+	vp.Proof.Value.VerificationMethod = "did:example:attacker#keys-1"
+
+	res, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.NoError(t, err)
+	assert.Empty(t, res.Errors)
+	assert.NotEmpty(t, res.Warnings)
+	assert.NotContains(t, res.Checks, CheckHolder)
+}
+
+func TestDIFValidatorService_ValidateSubjectIsHolderResolved(t *testing.T) {
+	presentationDefinition := createPresentationDefinition(t)
+	vp := createVerifiablePresentation(t)
+	presentationDefinition.InputDescriptors[1].SetSubjectIsHolder(models.Required)
+	vp.Proof.Value.VerificationMethod = "did:example:wallet#keys-1"
+	validator := NewDIFValidatorService(mockedSSIs, WithDidService(&mockDidService{
+		docs: map[string]*models.DIDDocument{
+			"did:example:ebfeb1f712ebc6f1c276e12ec21": {
+				Id: "did:example:ebfeb1f712ebc6f1c276e12ec21",
+				Authentication: []models.VerificationMethod{
+					{Reference: "did:example:wallet#keys-1"},
+				},
+			},
+		},
+	})).(*ValidatorServiceDIF)
+	validator.jVal = mockedJVal
+
+	res, err := validator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.NoError(t, err)
+	assert.Empty(t, res.Errors)
+	assert.Contains(t, res.Checks, CheckHolder)
+}
+
+func TestDIFValidatorService_ValidateSubmissionParallelOrder(t *testing.T) {
+	presentationDefinition := createPresentationDefinition(t)
+	vp := createVerifiablePresentation(t)
+	slowSSI := &mockSlowSSIService{
+		delays: map[string]time.Duration{
+			"cred:example:aeourhiuq4q38wq8q3": 30 * time.Millisecond, //First credential finishes last
+			"cred:example:employee12345":      10 * time.Millisecond,
+		},
+		fails: map[string]error{
+			"cred:example:employee12345":                 models.ErrMissingConstraint,
+			"https://eu.com/claims/DriversLicense/idnag": models.ErrInvalidFormat,
+		},
+	}
+	validator := &ValidatorServiceDIF{ssiS: slowSSI, jVal: mockedJVal, maxWorkers: 3}
+	res := createEmptyVerificationResult()
+
+	err := validator.validateSubmission(nil, res, presentationDefinition, vp, "")
+	assert.Error(t, err)
+	assert.Equal(t, models.ErrMissingConstraint, err) //Failure of the second credential is reported, not the third one
+	assert.Equal(t, []string{CheckSchema, CheckFormat, CheckIssuer, CheckCredential, CheckValidity, CheckExpiry, CheckStatus, CheckConstraints, CheckSchema, CheckFormat, CheckIssuer}, res.Checks)
+	assert.Equal(t, 2, len(res.Errors))
+}
+
+func TestDIFValidatorService_ValidateSubmissionCancelled(t *testing.T) {
+	presentationDefinition := createPresentationDefinition(t)
+	vp := createVerifiablePresentation(t)
+	reqCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/", nil).WithContext(reqCtx)
+	ctx := echo.New().NewContext(req, httptest.NewRecorder())
+	res := createEmptyVerificationResult()
+
+	err := difValidator.validateSubmission(ctx, res, presentationDefinition, vp, "")
+	assert.Error(t, err)
+	assert.Equal(t, context.Canceled, err)
+	assert.NotContains(t, res.Checks, CheckSubmission)
+}
+
+func TestDIFValidatorService_ValidateFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   interface{}
+		filter models.Filter
+		err    error
+	}{
+		{"Integer", float64(21), models.Filter{Type: "integer", Minimum: float64(18)}, nil},
+		{"Not integer", 21.5, models.Filter{Type: "integer"}, models.ErrMissingConstraint},
+		{"Number below minimum", float64(17), models.Filter{Type: "number", Minimum: float64(18)}, models.ErrMissingConstraint},
+		{"Number exclusive maximum", float64(18), models.Filter{Type: "number", ExclusiveMaximum: float64(18)}, models.ErrMissingConstraint},
+		{"Wrong type", float64(21), models.Filter{Type: "string"}, models.ErrMissingConstraint},
+		{"Date-time", "2010-01-01T19:43:24Z", models.Filter{Type: "string", Format: "date-time"}, nil},
+		{"Invalid date-time", "01/01/2010", models.Filter{Type: "string", Format: "date-time"}, models.ErrMissingConstraint},
+		{"Date-time bound", "2010-01-01T19:43:24Z", models.Filter{Type: "string", Format: "date-time", Minimum: "2011-01-01T00:00:00Z"}, models.ErrMissingConstraint},
+		{"Date", "1980-07-13T00:00:00Z", models.Filter{Type: "string", Format: "date", Maximum: "1999-6-15"}, nil},
+		{"Email", "john@example.com", models.Filter{Type: "string", Format: "email"}, nil},
+		{"Invalid email", "john.example.com", models.Filter{Type: "string", Format: "email"}, models.ErrMissingConstraint},
+		{"Uri", "https://example.com/claims", models.Filter{Type: "string", Format: "uri"}, nil},
+		{"Enum", float64(2), models.Filter{Type: "number", Enum: []models.StringOrInteger{float64(1), float64(2)}}, nil},
+		{"Const", "DE", models.Filter{Type: "string", Const: "US"}, models.ErrMissingConstraint},
+		{"Contains", []interface{}{"VerifiableCredential", "EmailCredential"}, models.Filter{Type: "array", Contains: &models.Filter{Type: "string", Const: "EmailCredential"}}, nil},
+		{"Not contains", []interface{}{"VerifiableCredential"}, models.Filter{Type: "array", Contains: &models.Filter{Type: "string", Const: "EmailCredential"}}, models.ErrMissingConstraint},
+		{"Nested not", "US", models.Filter{Type: "string", Not: &models.Filter{Type: "string", Not: &models.Filter{Enum: []models.StringOrInteger{"US", "JP"}}}}, nil},
+		{"Not", "US", models.Filter{Type: "string", Not: &models.Filter{Type: "string", Pattern: "^US"}}, models.ErrMissingConstraint},
+		{"Misspelt bound", float64(21), models.Filter{Type: "number", Minimum: "1O"}, models.ErrMissingConstraint},
+		{"Misspelt date bound", "2010-01-01T19:43:24Z", models.Filter{Type: "string", Format: "date-time", Maximum: "tomorrow"}, models.ErrMissingConstraint},
+		{"Nested date bound", "2010-01-01T19:43:24Z", models.Filter{Type: "string", Not: &models.Filter{Type: "string", Format: "date-time", Minimum: "2011-01-01T00:00:00Z"}}, models.ErrMissingConstraint},
+		{"Contains with bounds", []interface{}{float64(3), float64(20)}, models.Filter{Type: "array", Contains: &models.Filter{Type: "number", Minimum: float64(18)}}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter := test.filter
+			err := difValidator.validateFilter(nil, test.data, &filter)
+			assert.Equal(t, test.err, err)
+		})
+	}
+}
+
+func TestDIFValidatorService_ValidateFilterVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		data    interface{}
+		filter  models.Filter
+		err     error
+	}{
+		{"Numeric string", models.PEVersion1, "21", models.Filter{Type: "number", Minimum: "18"}, nil},
+		{"Numeric string v2", models.PEVersion2, "21", models.Filter{Type: "number", Minimum: "18"}, models.ErrMissingConstraint},
+		{"Boolean pattern", models.PEVersion1, false, models.Filter{Type: "boolean", Pattern: "true"}, models.ErrMissingConstraint},
+		{"Boolean pattern v2", models.PEVersion2, true, models.Filter{Type: "boolean", Pattern: "false"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter := test.filter
+			err := difValidator.forVersion(test.version).validateFilter(nil, test.data, &filter)
+			assert.Equal(t, test.err, err)
+		})
+	}
+}
+
+func TestDIFValidatorService_ValidateSchemaVersion(t *testing.T) {
+	vc := &createVerifiablePresentation(t).VerifiableCredential[0]
+
+	//Only PE v2 descriptors may leave the schema of the credential open
+	assert.NoError(t, difValidator.forVersion(models.PEVersion2).validateSchemas(nil, createEmptyVerificationResult(), vc, nil))
+	assert.Equal(t, models.ErrInvalidFormat, difValidator.forVersion(models.PEVersion1).validateSchemas(nil, createEmptyVerificationResult(), vc, nil))
+}
+
+func TestDIFValidatorService_ValidatePredicate(t *testing.T) {
+	predicate := models.Required
+	tests := []struct {
+		name    string
+		value   interface{}
+		err     error
+		warning bool
+	}{
+		{"Predicate result", true, nil, false},
+		{"Predicate not fullfilled", false, models.ErrMissingConstraint, false},
+		{"Raw value", float64(21), nil, true},
+		{"Raw value not fullfilled", float64(15), models.ErrMissingConstraint, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			presentationDefinition := createPresentationDefinition(t)
+			vp := createVerifiablePresentation(t)
+			for i, descriptor := range presentationDefinition.InputDescriptors {
+				if descriptor.ID == "banking_input_2" {
+					constraints := presentationDefinition.InputDescriptors[i].Constraints
+					constraints.Fields = append(constraints.Fields, models.Field{
+						Path:      []string{"$.credentialSubject.age"},
+						Filter:    &models.Filter{Type: "integer", Minimum: float64(18)},
+						Predicate: &predicate,
+					})
+				}
+			}
+			(*vp.VerifiableCredential[0].CredentialSubject)["age"] = test.value
+
+			res, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+			assert.Equal(t, test.err, err)
+			warning := "Credential cred:example:aeourhiuq4q38wq8q3 disclosed the value of the predicate field $.credentialSubject.age instead of its result"
+			if test.warning {
+				assert.Contains(t, res.Warnings, warning)
+			} else {
+				assert.NotContains(t, res.Warnings, warning)
+			}
+		})
+	}
+}
+
+func TestDIFValidatorService_ValidateRequirementFromNested(t *testing.T) {
+	one := 1
+	pd := &models.PresentationDefinition{}
+	pd.InputDescriptors = []models.InputDescriptor{
+		{ID: "passport", Group: []string{"P"}},
+		{ID: "id_card", Group: []string{"I"}},
+		{ID: "proof_of_address", Group: []string{"I"}},
+	}
+	// passport OR (ID card AND proof of address)
+	req := models.SubmissionRequirement{
+		Name:  "Identity",
+		Rule:  models.Pick,
+		Count: &one,
+		FromOption: models.FromOption{FromNested: []models.SubmissionRequirement{
+			{Name: "Passport", Rule: models.All, FromOption: models.FromOption{From: "P"}},
+			{Name: "ID card and address", Rule: models.All, FromOption: models.FromOption{From: "I"}},
+		}},
+	}
+	tests := []struct {
+		name        string
+		requirement models.SubmissionRequirement
+		submitted   []string
+		err         error
+		errors      int
+	}{
+		{"Passport", req, []string{"passport"}, nil, 0},
+		{"ID card and address", req, []string{"id_card", "proof_of_address"}, nil, 0},
+		{"Only ID card", req, []string{"id_card"}, models.ErrMissingRequirement, 3},
+		{"Both branches", req, []string{"passport", "id_card", "proof_of_address"}, models.ErrMissingRequirement, 1},
+		{"Nested twice", models.SubmissionRequirement{
+			Name:       "Onboarding",
+			Rule:       models.All,
+			FromOption: models.FromOption{FromNested: []models.SubmissionRequirement{req}},
+		}, []string{"id_card"}, models.ErrMissingRequirement, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subs := &models.PresentationSubmission{}
+			for _, id := range test.submitted {
+				subs.DescriptorMap = append(subs.DescriptorMap, models.Descriptor{ID: id})
+			}
+			res := createEmptyVerificationResult()
+
+			err := difValidator.validateRequirement(nil, res, &test.requirement, pd, subs, map[string]error{})
+			assert.Equal(t, test.err, err)
+			assert.Equal(t, test.errors, len(res.Errors))
+		})
+	}
+}
+
+func TestDIFValidatorService_ValidateDescriptorReport(t *testing.T) {
+	presentationDefinition := createPresentationDefinition(t)
+	vp := createVerifiablePresentation(t)
+
+	res, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(res.Descriptors))
+	report := res.Descriptors[0]
+	assert.Equal(t, "banking_input_2", report.DescriptorId)
+	assert.Equal(t, "cred:example:aeourhiuq4q38wq8q3", report.CredentialId)
+	assert.Equal(t, "$.verifiableCredential[0]", report.Path)
+	assert.Equal(t, models.CheckWarning, report.Status)
+	checks := map[string]models.CheckReport{}
+	fieldPaths := []string{}
+	for _, check := range report.Checks {
+		checks[check.Check] = check
+		if check.Check == CheckField {
+			fieldPaths = append(fieldPaths, check.Path)
+		}
+	}
+	assert.Equal(t, models.CheckPassed, checks[CheckCredential].Status)
+	assert.Equal(t, models.CheckWarning, checks[CheckStatus].Status)
+	assert.Equal(t, []string{"Credential status not available."}, checks[CheckStatus].Messages)
+	assert.Equal(t, []string{"$.issuer", "$.credentialSubject.account[*].id", "$.credentialSubject.account[*].route"}, fieldPaths)
+
+	server := newStatusServer(t, map[string]interface{}{
+		"/revocation": createStatusListCredential(constant.StatusPurposeRevocation, encodeStatusList(t, 5)),
+	})
+	defer server.Close()
+	vp.VerifiableCredential[0].CredentialStatus = &models.CredentialStatus{
+		Type:                 constant.StatusList2021Entry,
+		StatusPurpose:        constant.StatusPurposeRevocation,
+		StatusListIndex:      "5",
+		StatusListCredential: server.URL + "/revocation",
+	}
+
+	res, err = difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.Equal(t, models.ErrStatusRevoked, err)
+	assert.Equal(t, 3, len(res.Descriptors))
+	report = res.Descriptors[0]
+	assert.Equal(t, models.CheckFailed, report.Status)
+	assert.Equal(t, 10, len(report.Checks))
+	assert.Equal(t, CheckStatus, report.Checks[6].Check)
+	assert.Equal(t, models.CheckFailed, report.Checks[6].Status)
+	assert.Equal(t, "STATUS_REVOKED", report.Checks[6].Code)
+	assert.Equal(t, "$.credentialStatus", report.Checks[6].Path)
+	assert.Equal(t, models.CheckSkipped, report.Checks[7].Status)
+	assert.Equal(t, models.CheckSkipped, res.Descriptors[1].Status)
+	assert.Equal(t, models.CheckSkipped, res.Descriptors[2].Status)
+	assert.Equal(t, "citizenship_input_1", res.Descriptors[2].DescriptorId)
+}
+
+func TestDIFValidatorService_ValidateCollectAllErrors(t *testing.T) {
+	presentationDefinition := createPresentationDefinition(t)
+	vp := createVerifiablePresentation(t)
+	vp.PresentationSubmission.DefinitionID = "unknown"
+	vp.VerifiableCredential[1].CredentialSchema = &models.CredentialSchema{Id: "https://schema.org/nonexisting"}
+	count := 2
+	presentationDefinition.SubmissionRequirements[0].Count = &count
+
+	res, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.Equal(t, models.ErrNotMatch, err)
+	assert.Equal(t, 1, len(res.Errors))
+
+	policy := &models.VerificationPolicy{CollectAllErrors: true}
+	res, err = difValidator.ValidatePresentationResponseWithPolicy(nil, presentationDefinition, vp, "", policy)
+	assert.Equal(t, models.ErrNotMatch, err)
+	assert.Contains(t, res.Errors, "Id of presentation submission and definition are not matching")
+	assert.Contains(t, res.Errors, "Credential schema does not match requested schemas")
+	assert.Contains(t, res.Errors, "Required requirement Banking Information wasn't satisfied: only 1 instead of 2 desired satisfy the condition")
+	assert.Contains(t, res.Checks, CheckPresentation)
+	assert.NotContains(t, res.Checks, CheckSubmission)
+	assert.Equal(t, 3, len(res.Descriptors))
+	assert.Equal(t, models.CheckFailed, res.Descriptors[1].Status)
+	assert.Equal(t, models.CheckFailed, res.Descriptors[1].Checks[0].Status)
+	assert.Equal(t, models.CheckPassed, res.Descriptors[1].Checks[2].Status)
+	assert.NotEqual(t, models.CheckSkipped, res.Descriptors[2].Status)
+}
+
+type countingStatusResolver struct {
+	calls int
+}
+
+func (cs *countingStatusResolver) ResolveStatus(ctx echo.Context, cred *models.VerifiableCredential, requesterVMethod string) (string, error) {
+	cs.calls++
+	return constant.CredentialStatusIssued, nil
+}
+
+func TestDIFValidatorService_ValidateCollectAllSkipsForged(t *testing.T) {
+	resolver := &countingStatusResolver{}
+	forgedSSI := &mockSlowSSIService{fails: map[string]error{"cred:example:aeourhiuq4q38wq8q3": models.ErrInvalidSignature}}
+	validator := NewDIFValidatorService(forgedSSI, WithStatusResolver("CountingStatus", resolver)).(*ValidatorServiceDIF)
+	validator.jVal = mockedJVal
+	vp := createVerifiablePresentation(t)
+	for i := range vp.VerifiableCredential {
+		vp.VerifiableCredential[i].CredentialStatus = &models.CredentialStatus{Id: "status", Type: "CountingStatus"}
+	}
+
+	policy := &models.VerificationPolicy{CollectAllErrors: true}
+	res, err := validator.ValidatePresentationResponseWithPolicy(nil, createPresentationDefinition(t), vp, "", policy)
+	assert.Equal(t, models.ErrInvalidSignature, err)
+	//Only the authentic credentials have their status resolved
+	assert.Equal(t, len(vp.VerifiableCredential)-1, resolver.calls)
+	for _, report := range res.Descriptors {
+		if report.CredentialId != "cred:example:aeourhiuq4q38wq8q3" {
+			continue
+		}
+		for _, check := range report.Checks[len(report.Checks)-6:] {
+			assert.Equal(t, models.CheckSkipped, check.Status, check.Check)
+		}
+	}
+}
+
+func TestDIFValidatorService_ValidateTemporalValidity(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	validator := NewDIFValidatorService(mockedSSIs, WithClock(func() time.Time { return now }), WithClockSkew(5*time.Minute)).(*ValidatorServiceDIF)
+	validator.jVal = mockedJVal
+	date := func(t time.Time) *models.TimeWithFormat {
+		return &models.TimeWithFormat{Time: t}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(vc *models.VerifiableCredential)
+		err    error
+		errMsg string
+	}{
+		{"Valid", func(vc *models.VerifiableCredential) {
+			vc.ExpirationDate = date(now.Add(time.Hour))
+		}, nil, ""},
+		{"Expired", func(vc *models.VerifiableCredential) {
+			vc.ExpirationDate = date(now.Add(-time.Hour))
+		}, models.ErrCredentialExpired, "Credential cred:example:aeourhiuq4q38wq8q3 expired on 2021-06-01T11:00:00Z"},
+		{"Expired within skew", func(vc *models.VerifiableCredential) {
+			vc.ExpirationDate = date(now.Add(-time.Minute))
+		}, nil, ""},
+		{"Not valid yet", func(vc *models.VerifiableCredential) {
+			vc.ValidFrom = date(now.Add(time.Hour))
+		}, models.ErrCredentialNotYetValid, "Credential cred:example:aeourhiuq4q38wq8q3 is not valid until 2021-06-01T13:00:00Z"},
+		{"Issued within skew", func(vc *models.VerifiableCredential) {
+			vc.IssuanceDate = date(now.Add(time.Minute))
+		}, nil, ""},
+		{"Proof created in the future", func(vc *models.VerifiableCredential) {
+			vc.Proof.Value.Created = date(now.Add(time.Hour))
+		}, models.ErrCredentialNotYetValid, "Proof of credential cred:example:aeourhiuq4q38wq8q3 is created in the future"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			presentationDefinition := createPresentationDefinition(t)
+			vp := createVerifiablePresentation(t)
+			test.modify(&vp.VerifiableCredential[0])
+
+			res, err := validator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+			assert.Equal(t, test.err, err)
+			if test.err == nil {
+				assert.Contains(t, res.Checks, CheckValidity)
+				assert.Contains(t, res.Checks, CheckExpiry)
+			} else {
+				assert.Contains(t, res.Errors, test.errMsg)
+			}
+		})
+	}
+}
+
+func TestDIFValidatorService_ValidateBinding(t *testing.T) {
+	tests := []struct {
+		name      string
+		challenge string
+		domain    string
+		err       error
+	}{
+		{"Bound", "1f44d55f-f161-4938-a659-f8026467f126", "4jt78h47fh47", nil},
+		{"Unrestricted domain", "1f44d55f-f161-4938-a659-f8026467f126", "", nil},
+		{"Other exchange", "e1b67bbf-1a2b-4f93-8f57-0f5d8f1c1f2c", "4jt78h47fh47", models.ErrInvalidChallenge},
+		{"Other verifier", "1f44d55f-f161-4938-a659-f8026467f126", "verifier.example.com", models.ErrInvalidDomain},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			presentationDefinition := createPresentationDefinition(t)
+			presentationDefinition.Challenge = test.challenge
+			presentationDefinition.Domain = test.domain
+
+			res, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, createVerifiablePresentation(t), "")
+			assert.Equal(t, test.err, err)
+			if test.err == nil {
+				assert.Contains(t, res.Checks, CheckChallenge)
+			}
+		})
+	}
+
+	//Every proof of the presentation must be bound, not only the first one
+	presentationDefinition := createPresentationDefinition(t)
+	presentationDefinition.Challenge = "1f44d55f-f161-4938-a659-f8026467f126"
+	vp := createVerifiablePresentation(t)
+	replayed := *vp.Proof.Value
+	replayed.Challenge = "e1b67bbf-1a2b-4f93-8f57-0f5d8f1c1f2c"
+	vp.Proof = &models.SSIProof{Values: &[]models.Proof{*vp.Proof.Value, replayed}}
+	_, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.Equal(t, models.ErrInvalidChallenge, err)
+}
+
+func TestDIFValidatorService_ValidatePresentationCreated(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	validator := NewDIFValidatorService(mockedSSIs, WithClock(func() time.Time { return now }), WithClockSkew(5*time.Minute)).(*ValidatorServiceDIF)
+	validator.jVal = mockedJVal
+
+	vp := createVerifiablePresentation(t)
+	vp.Proof.Value.Created = &models.TimeWithFormat{Time: now.Add(time.Minute)}
+	_, err := validator.ValidatePresentationResponse(nil, createPresentationDefinition(t), vp, "")
+	assert.NoError(t, err)
+
+	vp.Proof.Value.Created = &models.TimeWithFormat{Time: now.Add(time.Hour)}
+	res, err := validator.ValidatePresentationResponse(nil, createPresentationDefinition(t), vp, "")
+	assert.Equal(t, models.ErrPresentationNotYetValid, err)
+	assert.Contains(t, res.Errors, "Proof of presentation is created in the future")
+}
+
+func TestDIFValidatorService_ValidateFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(pd *models.PresentationDefinition)
+		err    error
+		errMsg string
+	}{
+		{"Unrestricted", func(pd *models.PresentationDefinition) {}, nil, ""},
+		{"Accepted suites", func(pd *models.PresentationDefinition) {
+			pd.Format = &models.Format{
+				LDPVC: &models.LDPType{ProofType: []string{"Ed25519VerificationKey2018", "EcdsaSecp256k1VerificationKey2019", "RsaSignature2018"}},
+				LDPVP: &models.LDPType{ProofType: []string{"RsaSignature2018"}},
+			}
+		}, nil, ""},
+		{"Credential suite refused", func(pd *models.PresentationDefinition) {
+			pd.Format = &models.Format{LDPVC: &models.LDPType{ProofType: []string{"Ed25519VerificationKey2018", "EcdsaSecp256k1VerificationKey2019"}}}
+		}, models.ErrUnsupportedFormat, "Credential https://eu.com/claims/DriversLicense/idnag proof RsaSignature2018 is not accepted by the definition format"},
+		{"Descriptor format overrides definition", func(pd *models.PresentationDefinition) {
+			pd.Format = &models.Format{LDPVC: &models.LDPType{ProofType: []string{"RsaSignature2018"}}}
+			for i := range pd.InputDescriptors {
+				if pd.InputDescriptors[i].ID == "banking_input_2" {
+					pd.InputDescriptors[i].Format = &models.Format{JWTVC: &models.JWTType{Alg: []string{"ES256"}}}
+				}
+			}
+		}, models.ErrUnsupportedFormat, "Credential cred:example:aeourhiuq4q38wq8q3 proof Ed25519VerificationKey2018 is not accepted by the definition format"},
+		{"Presentation suite refused", func(pd *models.PresentationDefinition) {
+			pd.Format = &models.Format{LDPVP: &models.LDPType{ProofType: []string{"JsonWebSignature2020"}}}
+		}, models.ErrUnsupportedFormat, "Presentation proof RsaSignature2018 is not accepted by the definition format"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			presentationDefinition := createPresentationDefinition(t)
+			test.modify(presentationDefinition)
+			vp := createVerifiablePresentation(t)
+
+			res, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+			assert.Equal(t, test.err, err)
+			if test.err == nil {
+				assert.Contains(t, res.Checks, CheckFormat)
+			} else {
+				assert.Contains(t, res.Errors, test.errMsg)
+			}
+		})
+	}
+}
+
+func findDescriptor(pd *models.PresentationDefinition, id string) *models.InputDescriptor {
+	for i := range pd.InputDescriptors {
+		if pd.InputDescriptors[i].ID == id {
+			return &pd.InputDescriptors[i]
+		}
+	}
+	return nil
+}
+
+func createV2PresentationDefinition(t *testing.T, sameSubject models.Preference) *models.PresentationDefinition {
+	presentationDefinition := createPresentationDefinition(t)
+	banking := findDescriptor(presentationDefinition, "banking_input_2")
+	employment := findDescriptor(presentationDefinition, "employment_input")
+	banking.Schema = nil
+	banking.Constraints.Fields[1].SetID("account")
+	employment.Constraints.Fields[0].SetID("employment")
+	nickname := models.Field{Path: []string{"$.credentialSubject.nickname"}}
+	nickname.SetOptional(true)
+	banking.Constraints.Fields = append(banking.Constraints.Fields, nickname)
+	assert.NoError(t, employment.AddSameSubject(sameSubject, "account", "employment"))
+	return presentationDefinition
+}
+
+func TestDIFValidatorService_ValidateV2Definition(t *testing.T) {
+	presentationDefinition := createV2PresentationDefinition(t, models.Required)
+	findDescriptor(presentationDefinition, "banking_input_2").SetLimitDisclosure(models.Preferred)
+	vp := createVerifiablePresentation(t)
+	(*vp.VerifiableCredential[0].CredentialSubject)["name"] = "John Doe" //Not requested by any field
+	assert.Equal(t, models.PEVersion2, presentationDefinition.Version())
+
+	res, err := difValidator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.NoError(t, err)
+	assert.Empty(t, res.Errors)
+	assert.Contains(t, res.Checks, CheckSameSubject)
+	assert.NotContains(t, res.Checks, CheckDisclosure)
+	assert.NotEmpty(t, res.Warnings)
+}
+
+func TestDIFValidatorService_ValidateV2SameSubjectMismatch(t *testing.T) {
+	vp := createVerifiablePresentation(t)
+	(*vp.VerifiableCredential[1].CredentialSubject)["id"] = "did:example:212444"
+
+	res := createEmptyVerificationResult()
+	err := difValidator.validateSameSubject(nil, res, createV2PresentationDefinition(t, models.Required), vp, "")
+	assert.Equal(t, models.ErrSubjectMismatch, err)
+	assert.Equal(t, 1, len(res.Errors))
+
+	res = createEmptyVerificationResult()
+	err = difValidator.validateSameSubject(nil, res, createV2PresentationDefinition(t, models.Preferred), vp, "")
+	assert.NoError(t, err)
+	assert.Empty(t, res.Errors)
+	assert.Equal(t, 1, len(res.Warnings))
+	assert.NotContains(t, res.Checks, CheckSameSubject)
+	//Preferred directives don't stop the required ones from being enforced
+	presentationDefinition := createV2PresentationDefinition(t, models.Preferred)
+	banking := findDescriptor(presentationDefinition, "banking_input_2")
+	assert.NoError(t, banking.AddSameSubject(models.Required, "account", "employment"))
+	res = createEmptyVerificationResult()
+	err = difValidator.validateSameSubject(nil, res, presentationDefinition, vp, "")
+	assert.Equal(t, models.ErrSubjectMismatch, err)
+	assert.NotContains(t, res.Checks, CheckSameSubject)
+}
+
+func TestDIFValidatorService_FindSubmittedCredential(t *testing.T) {
+	vp := createVerifiablePresentation(t)
+	presentation := newSubmittedPresentation(vp, nil, nil)
+
+	direct, err := findSubmittedCredential(nil, models.Descriptor{ID: "banking_input", Path: "$.verifiableCredential[1]"}, presentation)
+	assert.NoError(t, err)
+	assert.Nil(t, presentation.tree)
+	decoded, err := findSubmittedCredential(nil, models.Descriptor{ID: "banking_input", Path: "$['verifiableCredential'][1]"}, presentation)
+	assert.NoError(t, err)
+	assert.NotNil(t, presentation.tree)
+	assert.Equal(t, vp.VerifiableCredential[1].Id, direct.Id)
+	assert.Equal(t, direct.Id, decoded.Id)
+	assert.Equal(t, direct.CredentialSubject, decoded.CredentialSubject)
+
+	_, err = findSubmittedCredential(nil, models.Descriptor{ID: "banking_input", Path: "$.verifiableCredential[5]"}, presentation)
+	assert.Equal(t, models.ErrInvalidFormat, err)
+}
+
+func TestDIFValidatorService_FindNestedCredential(t *testing.T) {
+	vp := createVerifiablePresentation(t)
+	nested := func(i int) models.Descriptor {
+		descriptor := models.Descriptor{ID: "banking_input", Path: "$", Format: models.CredentialFormat(models.LDPVP)}
+		descriptor.PathNested = &models.Descriptor{ID: "banking_input", Path: fmt.Sprintf("$.verifiableCredential[%d]", i), Format: models.CredentialFormat(models.LDPVC)}
+		return descriptor
+	}
+
+	//Credentials of nested presentations aren't trusted without verifying their proof
+	_, err := findSubmittedCredential(nil, nested(1), newSubmittedPresentation(vp, nil, nil))
+	assert.Equal(t, models.ErrInvalidSignature, err)
+	forged := newSubmittedPresentation(vp, func(nested *models.VerifiablePresentation) error {
+		return models.ErrInvalidSignature
+	}, nil)
+	_, err = findSubmittedCredential(nil, nested(1), forged)
+	assert.Equal(t, models.ErrInvalidSignature, err)
+
+	verifications := 0
+	presentation := newSubmittedPresentation(vp, func(nested *models.VerifiablePresentation) error {
+		verifications++
+		return nil
+	}, nil)
+	for i := range vp.VerifiableCredential {
+		cred, err := findSubmittedCredential(nil, nested(i), presentation)
+		assert.NoError(t, err)
+		assert.Equal(t, vp.VerifiableCredential[i].Id, cred.Id)
+	}
+	assert.Equal(t, 1, verifications)
+}
+
+// createImagePresentation returns the sample presentation with a photo of the given size in every credential
+func createImagePresentation(b *testing.B, size int) *models.VerifiablePresentation {
+	var pres models.VerifiablePresentation
+	err := json.Unmarshal([]byte(testdata.SampleVerifiablePresentation), &pres)
+	assert.NoError(b, err)
+	photo := base64.StdEncoding.EncodeToString(make([]byte, size))
+	for _, vc := range pres.VerifiableCredential {
+		(*vc.CredentialSubject)["photo"] = photo
+	}
+	return &pres
+}
+
+func BenchmarkDIFValidatorService_ValidateSubmission(b *testing.B) {
+	for _, size := range []int{1 << 10, 256 << 10} {
+		b.Run(fmt.Sprintf("Photo %dKB", size>>10), func(b *testing.B) {
+			var presDef models.PresentationDefinition
+			assert.NoError(b, json.Unmarshal([]byte(testdata.MultiGroupPresentationDefinition), &presDef))
+			vp := createImagePresentation(b, size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := difValidator.validateSubmission(nil, createEmptyVerificationResult(), &presDef, vp, "")
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDIFValidatorService_ValidateLevelOfAssurance(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	validator := NewDIFValidatorService(mockedSSIs, WithClock(func() time.Time { return now })).(*ValidatorServiceDIF)
+	validator.jVal = mockedJVal
+	var evidenced models.VerifiableCredential
+	assert.NoError(t, json.Unmarshal([]byte(testdata.GatacaCredentialEvidence), &evidenced))
+
+	tests := []struct {
+		name     string
+		required models.LevelOfAssurance
+		evidence *models.Evidence
+		err      error
+	}{
+		{"Physical verification", models.LoAHigh, evidenced.Evidence, nil},
+		{"Remote verification", models.LoAHigh, &models.Evidence{
+			Type:             evidenced.Evidence.Type,
+			SubjectPresence:  models.PresenceDigital,
+			DocumentPresence: models.PresenceDigital,
+			EvidenceDocument: evidenced.Evidence.EvidenceDocument,
+		}, models.ErrInsufficientAssurance},
+		{"Without evidence", models.LoALow, nil, models.ErrInsufficientAssurance},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			presentationDefinition := createPresentationDefinition(t)
+			for i := range presentationDefinition.InputDescriptors {
+				presentationDefinition.InputDescriptors[i].LevelOfAssurance = test.required
+			}
+			vp := createVerifiablePresentation(t)
+			for i := range vp.VerifiableCredential {
+				vp.VerifiableCredential[i].Evidence = test.evidence
+			}
+			res, err := validator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+			assert.Equal(t, test.err, err)
+			if test.err == nil {
+				assert.Contains(t, res.Checks, CheckAssurance)
+			} else {
+				assert.Equal(t, "INSUFFICIENT_ASSURANCE", res.Descriptors[0].Checks[7].Code)
+			}
+		})
+	}
+}
+
+func TestDIFValidatorService_ValidateIssuerTrust(t *testing.T) {
+	now := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	accreditation := func(level int, schema string, expiration time.Time) []models.TrustedIssuer {
+		return []models.TrustedIssuer{{
+			Dids: []string{"did:example:123#key-1"},
+			Accreditations: &models.Accreditations{
+				CredentialSchema: schema,
+				LevelOfTrust:     level,
+				ValidFrom:        &models.TimeWithFormat{Time: now.AddDate(-1, 0, 0)},
+				ExpirationDate:   &models.TimeWithFormat{Time: expiration},
+			},
+		}}
+	}
+	bankSchema := "https://bank-schemas.org/2.0.0/accounts.json"
+
+	tests := []struct {
+		name    string
+		issuers []models.TrustedIssuer
+		err     error
+	}{
+		{"Accredited issuer", accreditation(3, bankSchema, now.AddDate(1, 0, 0)), nil},
+		{"Lower level of trust", accreditation(1, bankSchema, now.AddDate(1, 0, 0)), models.ErrUntrustedIssuer},
+		{"Accredited for another schema", accreditation(3, "https://bank-schemas.org/2.0.0/loans.json", now.AddDate(1, 0, 0)), models.ErrUntrustedIssuer},
+		{"Accredited for a requested schema the credential doesn't match", accreditation(3, "https://bank-schemas.org/1.0.0/accounts.json", now.AddDate(1, 0, 0)), models.ErrUntrustedIssuer},
+		{"Accredited for a context of the credential", accreditation(3, "https://www.w3.org/2018/credentials/v1", now.AddDate(1, 0, 0)), models.ErrUntrustedIssuer},
+		{"Expired accreditation", accreditation(3, bankSchema, now.AddDate(0, -1, 0)), models.ErrUntrustedIssuer},
+		{"Unlisted issuer", nil, models.ErrUntrustedIssuer},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			governance := &mockGovernanceService{issuers: test.issuers}
+			validator := NewDIFValidatorService(mockedSSIs, WithGovernanceService(governance), WithClock(func() time.Time { return now })).(*ValidatorServiceDIF)
+			validator.jVal = mockedJVal
+			presentationDefinition := createPresentationDefinition(t)
+			assert.NoError(t, findDescriptor(presentationDefinition, "banking_input_2").SetTrustLevel(2))
+
+			res, err := validator.ValidatePresentationResponse(nil, presentationDefinition, createVerifiablePresentation(t), "")
+			assert.Equal(t, test.err, err)
+			if test.err == nil {
+				assert.Contains(t, res.Checks, CheckTrust)
+			}
+		})
+	}
+
+	//Issuers removed from the framework are rejected by the exchanges already open
+	governance := &mockGovernanceService{issuers: accreditation(3, bankSchema, now.AddDate(1, 0, 0))}
+	validator := NewDIFValidatorService(mockedSSIs, WithGovernanceService(governance), WithClock(func() time.Time { return now })).(*ValidatorServiceDIF)
+	validator.jVal = mockedJVal
+	presentationDefinition := createPresentationDefinition(t)
+	assert.NoError(t, findDescriptor(presentationDefinition, "banking_input_2").SetTrustLevel(2))
+	_, err := validator.ValidatePresentationResponse(nil, presentationDefinition, createVerifiablePresentation(t), "")
+	assert.NoError(t, err)
+	governance.issuers = nil
+	_, err = validator.ValidatePresentationResponse(nil, presentationDefinition, createVerifiablePresentation(t), "")
+	assert.Equal(t, models.ErrUntrustedIssuer, err)
+
+	_, err = difValidator.ValidatePresentationResponse(nil, presentationDefinition, createVerifiablePresentation(t), "")
+	assert.Equal(t, models.ErrUntrustedIssuer, err)
+}
+
+func TestDIFValidatorService_ValidatePathNested(t *testing.T) {
+	issuerPub, issuerKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	holderKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	validator := createJWTValidator(t, issuerPub, &holderKey.PublicKey)
+	var presentationDefinition models.PresentationDefinition
+	assert.NoError(t, json.Unmarshal([]byte(jwtPresentationDefinition), &presentationDefinition))
+	credential := createJWTCredential(t, issuerKey, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name   string
+		format string
+		err    error
+	}{
+		{"JWT VP nesting a JWT VC", "jwt_vp", nil},
+		{"Credential can't nest credentials", "jwt_vc", models.ErrInvalidFormat},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			//OpenID4VP vp_token: the envelope is the JWT VP and the credential is nested in its vp claim
+			token := createJWTPresentation(t, holderKey, credential, map[string]interface{}{
+				"id": "email_input", "format": test.format, "path": "$",
+				"path_nested": map[string]interface{}{"id": "email_input", "format": "jwt_vc", "path": "$.vp.verifiableCredential[0]"},
+			})
+			vp := &models.VerifiablePresentation{}
+			assert.NoError(t, json.Unmarshal([]byte(`"`+token+`"`), vp))
+
+			res, err := validator.ValidatePresentationResponse(nil, &presentationDefinition, vp, "")
+			assert.Equal(t, test.err, err)
+			if test.err == nil {
+				assert.Contains(t, res.Checks, CheckCredential)
+				assert.Contains(t, res.Checks, CheckSubmission)
+				assert.Equal(t, "$ > $.vp.verifiableCredential[0]", res.Descriptors[0].Path)
+			}
+		})
+	}
+}
+
+func TestDIFValidatorService_ValidateSecondFactors(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	factorPublic, factorKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	_, forgedKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	validator := NewDIFValidatorService(mockedSSIs, WithClock(func() time.Time { return now }), WithDidService(&mockDidService{
+		docs: map[string]*models.DIDDocument{
+			jwtHolder: {
+				Id:                 jwtHolder,
+				VerificationMethod: []*models.PublicKey{{Id: jwtHolder + "#email", Type: models.TypeEd25519, KeyB58: base58.Encode(factorPublic)}},
+				Authentication:     []models.VerificationMethod{{Reference: jwtHolder + "#email"}},
+			},
+			jwtIssuer: {
+				Id:                 jwtIssuer,
+				VerificationMethod: []*models.PublicKey{{Id: jwtIssuer + "#email", Type: models.TypeEd25519, KeyB58: base58.Encode(factorPublic)}},
+				Authentication:     []models.VerificationMethod{{Reference: jwtIssuer + "#email"}},
+			},
+		},
+	})).(*ValidatorServiceDIF)
+
+	factor := func(did, nonce string, issued time.Time, key ed25519.PrivateKey) models.Proof {
+		jws := encodeJWT(t, map[string]interface{}{"alg": "EdDSA", "kid": did + "#email"}, map[string]interface{}{
+			"nonce": nonce,
+			"aud":   "verifier.example.com",
+			"iat":   issued.Unix(),
+		}, func(input []byte) []byte {
+			return ed25519.Sign(key, input)
+		})
+		return models.Proof{Type: models.ProofTypeJWT, Creator: did + "#email", Jws: jws}
+	}
+	definition := func(patterns ...string) *models.PresentationDefinition {
+		descriptor := models.InputDescriptor{
+			ID:          "authnfactors",
+			Group:       []string{"factors"},
+			Constraints: &models.Constraints{},
+		}
+		assert.NoError(t, descriptor.SetMechanism(models.MechanismAuthnFactor))
+		for i, pattern := range patterns {
+			descriptor.Constraints.Fields = append(descriptor.Constraints.Fields, models.Field{
+				Id:     fmt.Sprintf("authn_factor_%d", i),
+				Path:   []string{"vp.proof.creator"},
+				Filter: &models.Filter{Type: "string", Pattern: pattern},
+			})
+			assert.NoError(t, descriptor.AddIsHolder(models.Required, fmt.Sprintf("authn_factor_%d", i)))
+		}
+		//Requirements are told apart by their descriptors, so their names can be translated
+		requirement := models.SubmissionRequirement{Name: "Verificación de identidad", Rule: models.All}
+		requirement.From = "factors"
+		pd := &models.PresentationDefinition{}
+		pd.ID = "second-factors"
+		pd.InputDescriptors = []models.InputDescriptor{descriptor}
+		pd.SubmissionRequirements = []models.SubmissionRequirement{requirement}
+		pd.Challenge = "challenge"
+		pd.Domain = "verifier.example.com"
+		return pd
+	}
+	validate := func(result *models.VerificationResult, pd *models.PresentationDefinition, vp *models.VerifiablePresentation) error {
+		return validator.validateSubmissionRequirements(nil, result, pd, &models.PresentationSubmission{}, vp)
+	}
+
+	missingJws := factor(jwtHolder, "challenge", now, factorKey)
+	missingJws.Jws = ""
+	tests := []struct {
+		name   string
+		factor models.Proof
+		err    error
+	}{
+		{"Signed factor", factor(jwtHolder, "challenge", now.Add(-time.Minute), factorKey), nil},
+		{"Factor without signature", missingJws, models.ErrMissingSFProof},
+		{"Factor of another exchange", factor(jwtHolder, "other-challenge", now, factorKey), models.ErrSFValidation},
+		{"Stale factor", factor(jwtHolder, "challenge", now.Add(-time.Hour), factorKey), models.ErrSFValidation},
+		{"Forged factor", factor(jwtHolder, "challenge", now, forgedKey), models.ErrSFValidation},
+		{"Factor of someone else", factor(jwtIssuer, "challenge", now, factorKey), models.ErrSFValidation},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			holder := jwtHolder
+			vp := &models.VerifiablePresentation{
+				Holder: &holder,
+				Proof:  &models.SSIProof{Values: &[]models.Proof{{Type: "Ed25519Signature2018", Creator: jwtHolder + "#key-1"}, test.factor}},
+			}
+			result := &models.VerificationResult{}
+			err := validate(result, definition("^did:(.*)#(email|phone)$"), vp)
+			assert.Equal(t, test.err, err)
+			if test.err != nil {
+				assert.Contains(t, result.Errors, test.err.Error())
+			} else {
+				assert.Contains(t, result.Checks, CheckIdentity)
+			}
+		})
+	}
+
+	//Presentations without proofs of the factor don't satisfy the descriptor
+	vp := &models.VerifiablePresentation{Proof: &models.SSIProof{Values: &[]models.Proof{{Creator: jwtHolder + "#key-1"}}}}
+	err = validate(&models.VerificationResult{}, definition("^did:(.*)#(email|phone)$"), vp)
+	assert.Equal(t, models.ErrMissingSecondFactor, err)
+
+	//Every field of the descriptor must be proved
+	vp.Proof = &models.SSIProof{Values: &[]models.Proof{{Creator: jwtHolder + "#key-1"}, factor(jwtHolder, "challenge", now, factorKey)}}
+	err = validate(&models.VerificationResult{}, definition("^did:(.*)#email$", "^did:(.*)#phone$"), vp)
+	assert.Equal(t, models.ErrMissingSecondFactor, err)
+	err = validate(&models.VerificationResult{}, definition("^did:(.*)#email$"), vp)
+	assert.NoError(t, err)
+
+	//Factors can't be bound to definitions without a challenge
+	unbound := definition("^did:(.*)#email$")
+	unbound.Challenge = ""
+	assert.Equal(t, models.ErrSFValidation, validate(&models.VerificationResult{}, unbound, vp))
+
+	//The holder is the signer of the presentation proof, not the one stated by the presentation
+	issuer := jwtIssuer
+	vp.Holder = &issuer
+	vp.Proof = &models.SSIProof{Values: &[]models.Proof{{Creator: jwtHolder + "#key-1"}, factor(jwtIssuer, "challenge", now, factorKey)}}
+	err = validate(&models.VerificationResult{}, definition("^did:(.*)#email$"), vp)
+	assert.Equal(t, models.ErrSFValidation, err)
+}
+
+func TestDIFValidatorService_ValidateLinkedDataSecondFactor(t *testing.T) {
+	factorPublic, factorKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	vp := createVerifiablePresentation(t)
+	holder := strings.Split(vp.Proof.Value.VerificationMethod, "#")[0]
+	validator := NewDIFValidatorService(mockedSSIs, WithDidService(&mockDidService{
+		docs: map[string]*models.DIDDocument{
+			holder: {
+				Id:                 holder,
+				VerificationMethod: []*models.PublicKey{{Id: holder + "#email", Type: models.TypeEd25519, KeyB58: base58.Encode(factorPublic)}},
+				Authentication:     []models.VerificationMethod{{Reference: holder + "#email"}},
+			},
+		},
+	})).(*ValidatorServiceDIF)
+	validator.jVal = mockedJVal
+
+	presentationDefinition := createPresentationDefinition(t)
+	presentationDefinition.Challenge = vp.Proof.Value.Challenge
+	presentationDefinition.Domain = vp.Proof.Value.Domain
+	presentationDefinition.Format = &models.Format{LDPVP: &models.LDPType{ProofType: []string{vp.Proof.Value.Type}}}
+	descriptor := models.InputDescriptor{ID: "authnfactors", Group: []string{"factors"}, Constraints: &models.Constraints{}}
+	assert.NoError(t, descriptor.SetMechanism(models.MechanismAuthnFactor))
+	descriptor.Constraints.Fields = []models.Field{{
+		Id:     "authn_factor",
+		Path:   []string{"vp.proof.creator"},
+		Filter: &models.Filter{Type: "string", Pattern: "^did:(.*)#email$"},
+	}}
+	assert.NoError(t, descriptor.AddIsHolder(models.Required, "authn_factor"))
+	requirement := models.SubmissionRequirement{Name: "Identity", Rule: models.All}
+	requirement.From = "factors"
+	presentationDefinition.InputDescriptors = append(presentationDefinition.InputDescriptors, descriptor)
+	presentationDefinition.SubmissionRequirements = append(presentationDefinition.SubmissionRequirements, requirement)
+
+	//The factor is a JWT proof next to the linked data proof of the presentation, which is still the one verified
+	jws := encodeJWT(t, map[string]interface{}{"alg": "EdDSA", "kid": holder + "#email"}, map[string]interface{}{
+		"nonce": presentationDefinition.Challenge,
+		"aud":   presentationDefinition.Domain,
+		"iat":   time.Now().Unix(),
+	}, func(input []byte) []byte {
+		return ed25519.Sign(factorKey, input)
+	})
+	vp.Proof = &models.SSIProof{Values: &[]models.Proof{*vp.Proof.Value, {Type: models.ProofTypeJWT, Creator: holder + "#email", Jws: jws}}}
+
+	res, err := validator.ValidatePresentationResponse(nil, presentationDefinition, vp, "")
+	assert.NoError(t, err)
+	assert.Empty(t, res.Errors)
+	assert.Contains(t, res.Checks, CheckIdentity)
+	assert.Contains(t, res.Checks, CheckPresentation)
+	assert.Contains(t, res.Checks, CheckChallenge)
+	assert.Contains(t, res.Checks, CheckFormat)
 }
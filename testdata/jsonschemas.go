@@ -78,6 +78,10 @@ const PresentationDefinitionSchema = `{
 		  "not": {
 			"type": "object",
 			"minProperties": 1
+		  },
+		  "contains": {
+			"type": "object",
+			"minProperties": 1
 		  }
 		},
 		"required": [
@@ -220,6 +224,9 @@ const PresentationDefinitionSchema = `{
 			  "type": "string"
 			}
 		  },
+		  "format": {
+			"$ref": "#/definitions/format"
+		  },
 		  "schema": {
 			"type": "array",
 			"items": {
@@ -230,7 +237,18 @@ const PresentationDefinitionSchema = `{
 			"type": "object",
 			"properties": {
 			  "limit_disclosure": {
-				"type": "boolean"
+				"oneOf": [
+				  {
+					"type": "boolean"
+				  },
+				  {
+					"type": "string",
+					"enum": [
+					  "required",
+					  "preferred"
+					]
+				  }
+				]
 			  },
 			  "fields": {
 				"type": "array",
@@ -251,14 +269,48 @@ const PresentationDefinitionSchema = `{
 				  "required",
 				  "preferred"
 				]
+			  },
+			  "is_holder": {
+				"type": "array",
+				"items": {
+				  "$ref": "#/definitions/subject_directive"
+				}
+			  },
+			  "same_subject": {
+				"type": "array",
+				"items": {
+				  "$ref": "#/definitions/subject_directive"
+				}
 			  }
 			},
 			"additionalProperties": false
 		  }
 		},
 		"required": [
-		  "id",
-		  "schema"
+		  "id"
+		],
+		"additionalProperties": false
+	  },
+	  "subject_directive": {
+		"type": "object",
+		"properties": {
+		  "field_id": {
+			"type": "array",
+			"items": {
+			  "type": "string"
+			}
+		  },
+		  "directive": {
+			"type": "string",
+			"enum": [
+			  "required",
+			  "preferred"
+			]
+		  }
+		},
+		"required": [
+		  "field_id",
+		  "directive"
 		],
 		"additionalProperties": false
 	  },
@@ -267,6 +319,15 @@ const PresentationDefinitionSchema = `{
 		"oneOf": [
 		  {
 			"properties": {
+			  "id": {
+				"type": "string"
+			  },
+			  "optional": {
+				"type": "boolean"
+			  },
+			  "intent_to_retain": {
+				"type": "boolean"
+			  },
 			  "path": {
 				"type": "array",
 				"items": {
@@ -287,6 +348,15 @@ const PresentationDefinitionSchema = `{
 		  },
 		  {
 			"properties": {
+			  "id": {
+				"type": "string"
+			  },
+			  "optional": {
+				"type": "boolean"
+			  },
+			  "intent_to_retain": {
+				"type": "boolean"
+			  },
 			  "path": {
 				"type": "array",
 				"items": {
@@ -379,3 +379,87 @@ const MultiGroupPresentationDefinition = `{
 		}
 	  ]
   }`
+
+const V2PresentationDefinition = `{
+	"presentation_definition": {
+	  "id": "9a2a4fd8-8d1b-4c0e-b5d3-5f0e6c4c1b2e",
+	  "input_descriptors": [
+		{
+		  "id": "banking_input",
+		  "name": "Bank Account Information",
+		  "purpose": "We need your bank and account information.",
+		  "format": {
+			"jwt_vc": {
+			  "alg": [
+				"EdDSA",
+				"ES256"
+			  ]
+			}
+		  },
+		  "constraints": {
+			"limit_disclosure": "required",
+			"fields": [
+			  {
+				"id": "account_holder",
+				"path": [
+				  "$.credentialSubject.account[*].id",
+				  "$.vc.credentialSubject.account[*].id"
+				],
+				"purpose": "We need the account to transfer the funds",
+				"intent_to_retain": true,
+				"filter": {
+				  "type": "string"
+				}
+			  },
+			  {
+				"path": [
+				  "$.credentialSubject.account[*].route"
+				],
+				"optional": true,
+				"filter": {
+				  "type": "string"
+				}
+			  }
+			],
+			"is_holder": [
+			  {
+				"field_id": [
+				  "account_holder"
+				],
+				"directive": "required"
+			  }
+			]
+		  }
+		},
+		{
+		  "id": "citizenship_input",
+		  "name": "US Passport",
+		  "constraints": {
+			"fields": [
+			  {
+				"id": "birth_date",
+				"path": [
+				  "$.credentialSubject.birth_date",
+				  "$.vc.credentialSubject.birth_date"
+				],
+				"filter": {
+				  "type": "string",
+				  "format": "date",
+				  "minimum": "1999-5-16"
+				}
+			  }
+			],
+			"same_subject": [
+			  {
+				"field_id": [
+				  "account_holder",
+				  "birth_date"
+				],
+				"directive": "preferred"
+			  }
+			]
+		  }
+		}
+	  ]
+	}
+  }`
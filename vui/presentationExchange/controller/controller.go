@@ -49,7 +49,7 @@ func NewPresentationExchangeHandler(e *echo.Echo, exchangeService presentationex
 // @Produce  json
 // @Param presentationDefinition body coreModels.PresentationDefinition true "Presentation definition of this exchange"
 // @Success 201 {object} PECreationResponse "Reference to the exchange process"
-// @Failure 400 {object} coreModels.ResponseMessage "Invalid input data."
+// @Failure 400 {object} coreModels.ResponseMessage "Invalid input data or presentation definition with authoring errors."
 // @Failure 403 {object} coreModels.ResponseMessage "Not Authorized to create exchanges."
 // @Failure 500 {object} coreModels.ResponseMessage "Serverside error processing the request."
 // @Router /api/v2/presentations [post]
@@ -246,6 +246,8 @@ func getStatusCode(err error) int {
 		return http.StatusNotFound
 	case coreModels.ErrConflict:
 		return http.StatusConflict
+	case coreModels.ErrInvalidDefinition:
+		return http.StatusBadRequest
 	default:
 		return http.StatusInternalServerError
 	}
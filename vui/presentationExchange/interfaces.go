@@ -24,6 +24,14 @@ type DataAgreementService interface {
 	Delete(c echo.Context, da *coreModels.DataAgreement) (*coreModels.DataAgreement, error)
 }
 
+// TenantConfigService manages the tenant configurations, rejecting the ones whose advanced definition has authoring errors
+type TenantConfigService interface {
+	GetTenantConfig(c echo.Context, tenant string) (*coreModels.TenantConfig, error)
+	CreateConfig(c echo.Context, config *coreModels.TenantConfig) error
+	UpdateConfig(c echo.Context, config *coreModels.TenantConfig) error
+	DeleteConfig(c echo.Context, tenant string) error
+}
+
 type PresExchangeDao interface {
 	Create(c echo.Context, pe *coreModels.PExchange) error
 	Update(c echo.Context, pe *coreModels.PExchange) error
@@ -32,6 +40,14 @@ type PresExchangeDao interface {
 	GetByID(c echo.Context, id string) (*coreModels.PExchange, error)
 }
 
+// ChallengeConsumer is implemented by the exchange DAOs able to use up the challenge of an exchange atomically
+type ChallengeConsumer interface {
+	PresExchangeDao
+	// UpdateUnusedChallenge updates the exchange only if its stored challenge hasn't been used yet, as a single atomic
+	// operation, and fails with ErrChallengeUsed otherwise
+	UpdateUnusedChallenge(c echo.Context, pe *coreModels.PExchange) error
+}
+
 type DataAgreementDao interface {
 	Create(c echo.Context, da *coreModels.DataAgreement) error
 	GetDataAgreement(c echo.Context, id string) (*coreModels.DataAgreement, error)
@@ -42,6 +42,12 @@ func (pes *peService) CreateFromTenant(c echo.Context, tenant string, credential
 		log.CDebugf(c, "Could not get tenant config,", err)
 		return nil, err
 	}
+	//Configurations stored before they were linted may still have authoring errors
+	issues, err := config.Lint()
+	if err != nil {
+		log.CErrorf(c, "Advanced definition of tenant %s has authoring errors: %v", tenant, issues)
+		return nil, err
+	}
 
 	dataAgreement := config.DataAgreementTemplate
 	pes.filterPresentationDefinitionAndDataAgreement(c, credentialsRequested, config, dataAgreement)
@@ -66,19 +72,25 @@ func (pes *peService) CreateFromTenant(c echo.Context, tenant string, credential
 		log.CError(c, "Cannot map input descriptor requirements", err)
 		return nil, err
 	}
+	challenge, err := newChallenge(c)
+	if err != nil {
+		return nil, err
+	}
 	definition := &coreModels.PresentationDefinition{
 		DIFPresentationDefinition: *difPE,
 		DataAgreement: &coreModels.DataAgreementRef{
 			DataAgreement: dataAgreement,
 		},
-		Proof: nil,
+		Challenge: challenge,
+		Domain:    config.Domain,
+		Proof:     nil,
 	}
 	err = pes.ssiService.SignPresentationDefinition(c, definition, config.DID)
 	if err != nil {
 		log.CError(c, "Cannot sign presentation definition", err)
 		return nil, err
 	}
-	_, err = pes.Create(c, definition)
+	_, err = pes.create(c, definition, config.Policy)
 	if err != nil {
 		log.CError(c, "Cannot store presentation exchange for validation", err)
 		return nil, err
@@ -87,20 +99,12 @@ func (pes *peService) CreateFromTenant(c echo.Context, tenant string, credential
 }
 
 func (pes *peService) Create(c echo.Context, pe *coreModels.PresentationDefinition) (*coreModels.PExchange, error) {
-	t := time.Now()
-	pex := &coreModels.PExchange{
-		Id:                     pe.ID,
-		PresentationDefinition: pe,
-		PresentationSubmission: nil,
-		CreatedAt:              &t,
-		UpdatedAt:              &t,
-	}
-	err := pes.peRepo.Create(c, pex)
+	issues, err := coreModels.Lint(pe)
 	if err != nil {
-		log.CError(c, "Presentation exchange couldn't be created", err)
+		log.CErrorf(c, "Presentation definition has authoring errors: %v", issues)
 		return nil, err
 	}
-	return pex, nil
+	return pes.create(c, pe, nil)
 }
 
 func (pes *peService) GetDefinition(c echo.Context, id string, dataAgreementOnly bool) (*coreModels.PresentationDefinition, error) {
@@ -133,6 +137,10 @@ func (pes *peService) Submit(c echo.Context, id string, verifiablePresentation *
 	if err != nil {
 		return nil, err
 	}
+	if pe.ChallengeUsedAt != nil {
+		log.CErrorf(c, "Challenge of presentation exchange %s already used at %s", pe.Id, pe.ChallengeUsedAt.Format(time.RFC3339))
+		return nil, coreModels.ErrChallengeUsed
+	}
 	pe.PresentationSubmission = verifiablePresentation
 	verificationResult, err := pes.verify(c, pe)
 
@@ -140,9 +148,8 @@ func (pes *peService) Submit(c echo.Context, id string, verifiablePresentation *
 	pe.Validations = verificationResult
 	pe.UpdatedAt = &t
 
-	err2 := pes.peRepo.Update(c, pe)
+	err2 := pes.consumeChallenge(c, pe, err == nil)
 	if err2 != nil {
-		log.CError(c, "Cannot update presentation exchange in db", err2)
 		return nil, err2
 	}
 
@@ -183,17 +190,92 @@ func (pes *peService) Delete(c echo.Context, id string) error {
 // ## PRIVATE
 // ############
 
+func (pes *peService) create(c echo.Context, pe *coreModels.PresentationDefinition, policy *coreModels.VerificationPolicy) (*coreModels.PExchange, error) {
+	if pe.Challenge == "" {
+		challenge, err := newChallenge(c)
+		if err != nil {
+			return nil, err
+		}
+		pe.Challenge = challenge
+	}
+	t := time.Now()
+	pex := &coreModels.PExchange{
+		Id:                     pe.ID,
+		PresentationDefinition: pe,
+		PresentationSubmission: nil,
+		Policy:                 policy,
+		Challenge:              pe.Challenge,
+		Domain:                 pe.Domain,
+		CreatedAt:              &t,
+		UpdatedAt:              &t,
+	}
+	err := pes.peRepo.Create(c, pex)
+	if err != nil {
+		log.CError(c, "Presentation exchange couldn't be created", err)
+		return nil, err
+	}
+	return pex, nil
+}
+
+// newChallenge generates the nonce that binds a presentation to a single exchange
+func newChallenge(c echo.Context) (string, error) {
+	challenge := tools.RandSeq(32)
+	if challenge == "" {
+		log.CError(c, "Cannot generate a challenge for the presentation exchange")
+		return "", coreModels.ErrInternalServerError
+	}
+	return challenge, nil
+}
+
+// consumeChallenge stores the outcome of a submission, marking the challenge of the exchange as used once a presentation
+// bound to it has been verified, so it can't be replayed. When the DAO is a ChallengeConsumer, the challenge being unused
+// is checked again as the exchange is stored, so only one of the submissions verified concurrently succeeds. Exchanges
+// created without a challenge are not enforced, to keep the ongoing ones working.
+func (pes *peService) consumeChallenge(c echo.Context, pe *coreModels.PExchange, verified bool) error {
+	if pe.Challenge == "" {
+		log.CWarnf(c, "Presentation exchange %s has no challenge to protect it from replays", pe.Id)
+		err := pes.peRepo.Update(c, pe)
+		if err != nil {
+			log.CError(c, "Cannot update presentation exchange in db", err)
+		}
+		return err
+	}
+	if verified {
+		t := time.Now()
+		pe.ChallengeUsedAt = &t
+	}
+	consumer, ok := pes.peRepo.(presentationexchange.ChallengeConsumer)
+	if !ok {
+		log.CWarnf(c, "Exchange DAO can't use up challenges atomically, concurrent submissions of exchange %s may reuse its challenge", pe.Id)
+		err := pes.peRepo.Update(c, pe)
+		if err != nil {
+			log.CError(c, "Cannot update presentation exchange in db", err)
+		}
+		return err
+	}
+	err := consumer.UpdateUnusedChallenge(c, pe)
+	if err == coreModels.ErrChallengeUsed {
+		log.CErrorf(c, "Challenge of presentation exchange %s used by a concurrent submission", pe.Id)
+		return err
+	}
+	if err != nil {
+		log.CError(c, "Cannot update presentation exchange in db", err)
+		return err
+	}
+	return nil
+}
+
 func (pes *peService) verify(c echo.Context, pe *coreModels.PExchange) (*coreModels.VerificationResult, error) {
-	verificationResult, err := pes.validator.ValidatePresentationResponse(c, pe.PresentationDefinition, pe.PresentationSubmission, pe.PresentationDefinition.DataAgreement.DataAgreement.DataReceiver.ID)
+	verificationResult, err := pes.validate(c, pe)
 	if err != nil {
 		return verificationResult, err
 	}
 	verificationResult.Checks = append(verificationResult.Checks, "consent")
 	presentationCreators := pe.PresentationSubmission.GetProofs().GetCreators()
-	dataSubject := (*pe.PresentationSubmission.VerifiableCredential[0].CredentialSubject)["id"].(string)
+	dataSubjects := credentialSubjects(pe.PresentationSubmission)
 	if pe.PresentationSubmission.DataAgreementId == "" {
 		log.CWarn(c, "Presentation Submission not enforcing data agreement, it is not from Gataca. Check if it is signed by the user")
-		if !presentInArray(presentationCreators, dataSubject) {
+		if !signedBySubjects(presentationCreators, dataSubjects) {
 			log.CError(c, "Cannot trust source of the credential")
 			errConsent := coreModels.ErrConsentValidation
 			verificationResult.Errors = append(verificationResult.Errors, errConsent.Error())
@@ -207,12 +289,21 @@ func (pes *peService) verify(c echo.Context, pe *coreModels.PExchange) (*coreMod
 		verificationResult.Errors = append(verificationResult.Errors, errConsent.Error())
 		return verificationResult, coreModels.ErrConsentValidation
 	}
-	if dataAgreement.DataSubject != dataSubject {
+	if !tools.Contains(dataSubjects, dataAgreement.DataSubject) {
 		log.CError(c, "Cannot data agreement doesn't allow the usage of this subject")
 		errConsent := coreModels.ErrConsentValidation
 		verificationResult.Errors = append(verificationResult.Errors, errConsent.Error())
 		return verificationResult, coreModels.ErrConsentValidation
 	}
+	//Subjects not bound to the data subject by same_subject need their own consent in the agreement
+	for _, subject := range dataSubjects {
+		if subject != dataAgreement.DataSubject && !tools.Contains(dataAgreement.RepresentedSubjects, subject) {
+			log.CErrorf(c, "Cannot data agreement doesn't cover the data of subject %s", subject)
+			errConsent := coreModels.ErrConsentValidation
+			verificationResult.Errors = append(verificationResult.Errors, errConsent.Error())
+			return verificationResult, coreModels.ErrConsentValidation
+		}
+	}
 	if !presentInArray(presentationCreators, dataAgreement.DataHolder) {
 		log.CError(c, "Cannot data agreement doesn't allow to trust the holder of these credentials")
 		errConsent := coreModels.ErrConsentValidation
@@ -415,7 +506,7 @@ func (pes *peService) addSecurityConfig(c echo.Context, config *coreModels.Tenan
 			Purpose: "Additional mechanisms of identity verification",
 			Rule:    "all",
 			FromOption: coreModels.FromOption{
-				From: "identity",
+				From: coreModels.IdentityGroup,
 			},
 		}
 		subRequirements = append(subRequirements, sr)
@@ -425,7 +516,8 @@ func (pes *peService) addSecurityConfig(c echo.Context, config *coreModels.Tenan
 			switch sec.Type {
 			case coreModels.AppAuth:
 				trustedWallets := strings.Join(pes.trustedWallets, "|")
-				id := pes.buildGenericInputDescriptor("wallet_auth", "Wallet Application Authentication", "We need to assert the source of the credentials as a trusted wallet", "identity", "vp.proof.creator", "The presentation must be signed with one of the trusted app dids", "^("+trustedWallets+")#appAuth$")
+				id := pes.buildGenericInputDescriptor("wallet_auth", "Wallet Application Authentication", "We need to assert the source of the credentials as a trusted wallet", coreModels.IdentityGroup, "vp.proof.creator", "The presentation must be signed with one of the trusted app dids", "^("+trustedWallets+")#appAuth$")
+				id.Mechanism = coreModels.MechanismWalletAuth
 				inputDescriptors = append(inputDescriptors, id)
 			case coreModels.AuthNFactor:
 				authFactors = append(authFactors, sec.Accepted...)
@@ -433,7 +525,9 @@ func (pes *peService) addSecurityConfig(c echo.Context, config *coreModels.Tenan
 		}
 		if len(authFactors) > 0 {
 			authFactorsParam := strings.Join(authFactors, "|")
-			id := pes.buildGenericInputDescriptor("authnfactors", "Identity verification enforced", "We need to assert the holder's identity matching the subject", "identity", "vp.proof.creator", "A second factor must be enforced", "^did:(.*)#("+authFactorsParam+")$")
+			id := pes.buildGenericInputDescriptor("authnfactors", "Identity verification enforced", "We need to assert the holder's identity matching the subject", coreModels.IdentityGroup, "vp.proof.creator", "A second factor must be enforced", "^did:(.*)#("+authFactorsParam+")$")
+			//Factors are bound to the holder by the validator, so declaring is_holder here would only turn v1 configurations into v2
+			id.Mechanism = coreModels.MechanismAuthnFactor
 			inputDescriptors = append(inputDescriptors, id)
 		}
 	}
@@ -455,10 +549,17 @@ func (pes *peService) buildCredentialInputDescriptor(ctx echo.Context, cred core
 	return id, nil
 }
 
+// getIssuersForTrustedLevel requires the issuer of the credential to be accredited with the level of trust requested
+// by the tenant. The accreditation is checked by the validator against the governance framework when the credential is
+// submitted, so issuers accredited or removed after the exchange is created are taken into account.
 func (pes *peService) getIssuersForTrustedLevel(ctx echo.Context, cred coreModels.CredentialRequest, id *coreModels.InputDescriptor, configDID string) error {
 	if cred.TrustLevel == 0 {
 		return nil
 	}
+	id.TrustLevel = cred.TrustLevel
+	if pes.govS == nil {
+		return nil
+	}
 	mappedSchemas := []string{}
 	for _, schema := range id.Schema {
 		mappedSchemas = append(mappedSchemas, schema.URI)
@@ -468,23 +569,9 @@ func (pes *peService) getIssuersForTrustedLevel(ctx echo.Context, cred coreModel
 		log.CError(ctx, "Error retrieving catalogs", err)
 		return err
 	}
-	issuers := []string{}
-	for _, ti := range trustedIssuers {
-		issuers = append(issuers, ti.Dids...)
+	if len(trustedIssuers) == 0 {
+		log.CWarnf(ctx, "No issuer is currently trusted for credential %s", cred.Type)
 	}
-	issPattern := "(" + strings.Join(issuers, "|") + ")"
-	issuerConstraint := coreModels.Field{
-		Path: []string{"$.issuer",
-			"$.vc.issuer",
-			"$.iss",
-		},
-		Purpose: "Assert the trust of the issuer",
-		Filter: &coreModels.Filter{
-			Type:    "string",
-			Pattern: issPattern,
-		},
-	}
-	id.Constraints.Fields = append(id.Constraints.Fields, issuerConstraint)
 	return nil
 }
 
@@ -513,6 +600,49 @@ func (pes *peService) buildGenericInputDescriptor(id string, name string, purpos
 	}
 }
 
+// validate verifies the submission of the exchange, enforcing its policy when the validator supports policies.
+// Other validators apply their default policy, which tolerates no credential status.
+func (pes *peService) validate(c echo.Context, pe *coreModels.PExchange) (*coreModels.VerificationResult, error) {
+	verifier := pe.PresentationDefinition.DataAgreement.DataAgreement.DataReceiver.ID
+	if validator, ok := pes.validator.(coreServices.PolicyValidator); ok {
+		return validator.ValidatePresentationResponseWithPolicy(c, pe.PresentationDefinition, pe.PresentationSubmission, verifier, pe.Policy)
+	}
+	if pe.Policy != nil {
+		log.CWarnf(c, "Validator doesn't support policies, exchange %s is verified with its default policy", pe.Id)
+	}
+	return pes.validator.ValidatePresentationResponse(c, pe.PresentationDefinition, pe.PresentationSubmission, verifier)
+}
+
+// credentialSubjects returns the distinct subjects of the credentials of a presentation, which can be several
+// when the holder presents credentials of others, like a guardian or the representative of an organisation
+func credentialSubjects(vp *coreModels.VerifiablePresentation) []string {
+	subjects := []string{}
+	for _, cred := range vp.VerifiableCredential {
+		if cred.CredentialSubject == nil {
+			continue
+		}
+		subject, _ := (*cred.CredentialSubject)["id"].(string)
+		if subject != "" && !tools.Contains(subjects, subject) {
+			subjects = append(subjects, subject)
+		}
+	}
+	return subjects
+}
+
+// signedBySubjects tells if the presentation is proved by every subject of its credentials, as without a data agreement
+// each of them must consent by signing it
+func signedBySubjects(creators []string, subjects []string) bool {
+	if len(subjects) == 0 {
+		return false
+	}
+	for _, subject := range subjects {
+		if !presentInArray(creators, subject) {
+			return false
+		}
+	}
+	return true
+}
+
 func presentInArray(arr []string, s string) bool {
 	for _, arrS := range arr {
 		if strings.Contains(arrS, s) {
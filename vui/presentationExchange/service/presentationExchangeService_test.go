@@ -0,0 +1,305 @@
+package service
+
+import (
+	"testing"
+
+	coreModels "github.com/gataca-io/vui-core/models"
+	presentationexchange "github.com/gataca-io/vui-core/vui/presentationExchange"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockPresExchangeDao struct {
+	exchanges map[string]*coreModels.PExchange
+}
+
+func (md *mockPresExchangeDao) Create(c echo.Context, pe *coreModels.PExchange) error {
+	stored := *pe
+	md.exchanges[pe.Id] = &stored
+	return nil
+}
+func (md *mockPresExchangeDao) Update(c echo.Context, pe *coreModels.PExchange) error {
+	stored := *pe
+	md.exchanges[pe.Id] = &stored
+	return nil
+}
+func (md *mockPresExchangeDao) UpdateUnusedChallenge(c echo.Context, pe *coreModels.PExchange) error {
+	if stored, ok := md.exchanges[pe.Id]; ok && stored.ChallengeUsedAt != nil {
+		return coreModels.ErrChallengeUsed
+	}
+	return md.Update(c, pe)
+}
+func (md *mockPresExchangeDao) DeleteLogical(id string) error {
+	return nil
+}
+func (md *mockPresExchangeDao) Delete(id string) error {
+	delete(md.exchanges, id)
+	return nil
+}
+func (md *mockPresExchangeDao) GetByID(c echo.Context, id string) (*coreModels.PExchange, error) {
+	if pe, ok := md.exchanges[id]; ok {
+		found := *pe
+		return &found, nil
+	}
+	return nil, coreModels.ErrNotFound
+}
+
+// legacyPresExchangeDao only promotes the methods of PresExchangeDao, like a DAO written before ChallengeConsumer
+type legacyPresExchangeDao struct {
+	presentationexchange.PresExchangeDao
+}
+
+type mockValidator struct {
+	calls int
+	err   error
+}
+
+func (mv *mockValidator) ValidatePresentationResponse(ctx echo.Context, pr coreModels.ExchangeRequest, resp coreModels.ExchangeResponse, requesterVMethod string) (*coreModels.VerificationResult, error) {
+	return mv.ValidatePresentationResponseWithPolicy(ctx, pr, resp, requesterVMethod, nil)
+}
+func (mv *mockValidator) ValidatePresentationResponseWithPolicy(ctx echo.Context, pr coreModels.ExchangeRequest, resp coreModels.ExchangeResponse, requesterVMethod string, policy *coreModels.VerificationPolicy) (*coreModels.VerificationResult, error) {
+	mv.calls++
+	if mv.err != nil {
+		return &coreModels.VerificationResult{Checks: []string{}, Errors: []string{"Submitted credentials don't satisfy descriptor requirements"}}, mv.err
+	}
+	return &coreModels.VerificationResult{Checks: []string{}, Errors: []string{}}, nil
+}
+
+type mockDataAgreementService struct {
+	da *coreModels.DataAgreement
+}
+
+func (ms *mockDataAgreementService) Create(c echo.Context, da *coreModels.DataAgreement) (*coreModels.DataAgreement, error) {
+	return da, nil
+}
+func (ms *mockDataAgreementService) GetDataAgreement(c echo.Context, id string, version int) (*coreModels.DataAgreement, error) {
+	return ms.da, nil
+}
+func (ms *mockDataAgreementService) Update(c echo.Context, da *coreModels.DataAgreement) (*coreModels.DataAgreement, error) {
+	return da, nil
+}
+func (ms *mockDataAgreementService) Delete(c echo.Context, da *coreModels.DataAgreement) (*coreModels.DataAgreement, error) {
+	return da, nil
+}
+
+func createPresentation(challenge, domain string) *coreModels.VerifiablePresentation {
+	return &coreModels.VerifiablePresentation{Proof: &coreModels.SSIProof{Value: &coreModels.Proof{
+		Type:      "Ed25519Signature2018",
+		Challenge: challenge,
+		Domain:    domain,
+	}}}
+}
+
+func TestPresExchangeService_SubmitChallenge(t *testing.T) {
+	dao := &mockPresExchangeDao{exchanges: map[string]*coreModels.PExchange{}}
+	das := &mockDataAgreementService{da: &coreModels.DataAgreement{
+		DataHolder:   "did:example:holder",
+		DataSubject:  "did:example:holder",
+		PersonalData: []coreModels.PersonalDatum{{AttributeID: "cred:holder"}},
+	}}
+	validator := &mockValidator{err: coreModels.ErrInvalidChallenge}
+	pes := NewPresentationExchangeService(dao, das, validator, nil, nil, nil, nil, false)
+
+	definition := &coreModels.PresentationDefinition{
+		DataAgreement: &coreModels.DataAgreementRef{DataAgreement: &coreModels.DataAgreement{}},
+		Domain:        "https://verifier.example.com",
+	}
+	definition.ID = "exchange-1"
+	exchange, err := pes.Create(nil, definition)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, exchange.Challenge)
+	assert.Equal(t, exchange.Challenge, definition.Challenge)
+
+	other := &coreModels.PresentationDefinition{DataAgreement: definition.DataAgreement}
+	other.ID = "exchange-2"
+	_, err = pes.Create(nil, other)
+	assert.NoError(t, err)
+	assert.NotEqual(t, definition.Challenge, other.Challenge)
+
+	submit := func() (*coreModels.VerificationResult, error) {
+		vp := createPresentation(definition.Challenge, definition.Domain)
+		vp.Proof.Value.VerificationMethod = "did:example:holder#key-1"
+		vp.VerifiableCredential = []coreModels.VerifiableCredential{createCredential("cred:holder", "did:example:holder")}
+		return pes.Submit(nil, "exchange-1", vp)
+	}
+
+	//Presentations that aren't verified don't use up the challenge, so anyone can't burn the exchange
+	_, err = submit()
+	assert.Equal(t, coreModels.ErrInvalidChallenge, err)
+	assert.Nil(t, dao.exchanges["exchange-1"].ChallengeUsedAt)
+	concurrent, err := pes.GetExchange(nil, "exchange-1")
+	assert.NoError(t, err)
+
+	validator.err = nil
+	res, err := submit()
+	assert.NoError(t, err)
+	assert.Contains(t, res.Checks, "consent")
+	assert.NotNil(t, dao.exchanges["exchange-1"].ChallengeUsedAt)
+
+	_, err = submit()
+	assert.Equal(t, coreModels.ErrChallengeUsed, err)
+	assert.Equal(t, 2, validator.calls)
+
+	//A submission verified concurrently can't store its outcome once the challenge is used
+	assert.Equal(t, coreModels.ErrChallengeUsed, pes.(*peService).consumeChallenge(nil, concurrent, true))
+	assert.Equal(t, res, dao.exchanges["exchange-1"].Validations)
+}
+
+func TestPresExchangeService_SubmitChallengeWithoutConsumer(t *testing.T) {
+	dao := &mockPresExchangeDao{exchanges: map[string]*coreModels.PExchange{}}
+	das := &mockDataAgreementService{da: &coreModels.DataAgreement{
+		DataHolder:   "did:example:holder",
+		DataSubject:  "did:example:holder",
+		PersonalData: []coreModels.PersonalDatum{{AttributeID: "cred:holder"}},
+	}}
+	validator := &mockValidator{}
+	pes := NewPresentationExchangeService(legacyPresExchangeDao{dao}, das, validator, nil, nil, nil, nil, false)
+
+	definition := &coreModels.PresentationDefinition{DataAgreement: &coreModels.DataAgreementRef{DataAgreement: &coreModels.DataAgreement{}}}
+	definition.ID = "exchange-1"
+	_, err := pes.Create(nil, definition)
+	assert.NoError(t, err)
+
+	submit := func() (*coreModels.VerificationResult, error) {
+		vp := createPresentation(definition.Challenge, definition.Domain)
+		vp.Proof.Value.VerificationMethod = "did:example:holder#key-1"
+		vp.VerifiableCredential = []coreModels.VerifiableCredential{createCredential("cred:holder", "did:example:holder")}
+		return pes.Submit(nil, "exchange-1", vp)
+	}
+	_, err = submit()
+	assert.NoError(t, err)
+	assert.NotNil(t, dao.exchanges["exchange-1"].ChallengeUsedAt)
+
+	_, err = submit()
+	assert.Equal(t, coreModels.ErrChallengeUsed, err)
+	assert.Equal(t, 1, validator.calls)
+}
+
+func createCredential(id, subject string) coreModels.VerifiableCredential {
+	return coreModels.VerifiableCredential{Id: id, CredentialSubject: &map[string]interface{}{"id": subject}}
+}
+
+func TestPresExchangeService_SubmitMultiSubject(t *testing.T) {
+	dao := &mockPresExchangeDao{exchanges: map[string]*coreModels.PExchange{}}
+	das := &mockDataAgreementService{}
+	pes := NewPresentationExchangeService(dao, das, &mockValidator{}, nil, nil, nil, nil, false)
+
+	tests := []struct {
+		name        string
+		signer      string
+		represented []string
+		err         error
+	}{
+		{"Guardian presenting the credential of a minor", "did:example:guardian#key-1", []string{"did:example:minor"}, nil},
+		{"Minor not covered by the agreement", "did:example:guardian#key-1", nil, coreModels.ErrConsentValidation},
+		{"Presentation not signed by any subject", "did:example:attacker#key-1", []string{"did:example:minor"}, coreModels.ErrConsentValidation},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			das.da = &coreModels.DataAgreement{
+				DataHolder:          "did:example:guardian",
+				DataSubject:         "did:example:guardian",
+				RepresentedSubjects: test.represented,
+				PersonalData:        []coreModels.PersonalDatum{{AttributeID: "cred:guardian"}, {AttributeID: "cred:minor"}},
+			}
+			definition := &coreModels.PresentationDefinition{DataAgreement: &coreModels.DataAgreementRef{DataAgreement: &coreModels.DataAgreement{}}}
+			definition.ID = test.name
+			_, err := pes.Create(nil, definition)
+			assert.NoError(t, err)
+
+			vp := createPresentation(definition.Challenge, "")
+			vp.Proof.Value.VerificationMethod = test.signer
+			vp.DataAgreementId = "agreement-1"
+			vp.VerifiableCredential = []coreModels.VerifiableCredential{
+				createCredential("cred:guardian", "did:example:guardian"),
+				createCredential("cred:minor", "did:example:minor"),
+			}
+			res, err := pes.Submit(nil, definition.ID, vp)
+			assert.Equal(t, test.err, err)
+			if test.err == nil {
+				assert.Contains(t, res.Checks, "consent")
+			}
+		})
+	}
+}
+
+func TestPresExchangeService_SignedBySubjects(t *testing.T) {
+	creators := []string{"did:example:guardian#key-1"}
+	assert.True(t, signedBySubjects(creators, []string{"did:example:guardian"}))
+	//Without a data agreement, the minor can't consent through the signature of the guardian
+	assert.False(t, signedBySubjects(creators, []string{"did:example:guardian", "did:example:minor"}))
+	assert.False(t, signedBySubjects(creators, nil))
+}
+
+func TestPresExchangeService_CreateKeepsDefinition(t *testing.T) {
+	pes := NewPresentationExchangeService(&mockPresExchangeDao{exchanges: map[string]*coreModels.PExchange{}}, nil, &mockValidator{}, nil, nil, nil, nil, false).(*peService)
+	descriptor := func(id string) coreModels.InputDescriptor {
+		return pes.buildGenericInputDescriptor(id, id, "", "mandatory", "$.type[-1:]", "", id)
+	}
+	//Credentials of several subjects are accepted unless the author relates them with same_subject
+	definition := &coreModels.PresentationDefinition{DataAgreement: &coreModels.DataAgreementRef{DataAgreement: &coreModels.DataAgreement{}}}
+	definition.ID = "exchange-1"
+	definition.InputDescriptors = []coreModels.InputDescriptor{descriptor("emailCredential"), descriptor("phoneCredential")}
+	_, err := pes.Create(nil, definition)
+	assert.NoError(t, err)
+	for _, descriptor := range definition.InputDescriptors {
+		assert.Empty(t, descriptor.Constraints.SameSubject)
+		assert.Empty(t, descriptor.Constraints.Fields[0].Id)
+	}
+	assert.Equal(t, coreModels.PEVersion1, definition.Version())
+}
+
+func createInvalidDefinition() *coreModels.PresentationDefinition {
+	definition := &coreModels.PresentationDefinition{DataAgreement: &coreModels.DataAgreementRef{DataAgreement: &coreModels.DataAgreement{}}}
+	definition.InputDescriptors = []coreModels.InputDescriptor{{
+		ID:    "email_input",
+		Group: []string{"A"},
+		Constraints: &coreModels.Constraints{Fields: []coreModels.Field{{
+			Path:   []string{"$.credentialSubject.email"},
+			Filter: &coreModels.Filter{Type: "string", Pattern: "[a-z+@"},
+		}}},
+	}}
+	requirement := coreModels.SubmissionRequirement{Rule: coreModels.All}
+	requirement.From = "B"
+	definition.SubmissionRequirements = []coreModels.SubmissionRequirement{requirement}
+	definition.ID = "exchange-1"
+	return definition
+}
+
+func TestPresExchangeService_CreateInvalidDefinition(t *testing.T) {
+	dao := &mockPresExchangeDao{exchanges: map[string]*coreModels.PExchange{}}
+	pes := NewPresentationExchangeService(dao, nil, &mockValidator{}, nil, nil, nil, nil, false)
+
+	_, err := pes.Create(nil, createInvalidDefinition())
+	assert.Equal(t, coreModels.ErrInvalidDefinition, err)
+	assert.Empty(t, dao.exchanges)
+}
+
+func TestPresExchangeService_CreateFromTenantSecurityVersion(t *testing.T) {
+	pes := NewPresentationExchangeService(nil, nil, &mockValidator{}, nil, nil, nil, []string{"did:example:wallet"}, false).(*peService)
+	config := &coreModels.TenantConfig{
+		TenantId:    "tenant",
+		Credentials: []coreModels.CredentialRequest{{Type: "emailCredential", Mandatory: true}},
+		Security: []coreModels.SecMechanism{
+			{Type: coreModels.AppAuth},
+			{Type: coreModels.AuthNFactor, Accepted: []string{"email", "phone"}},
+		},
+	}
+
+	//Security mechanisms of a v1 tenant configuration don't turn its definition into v2
+	definition, err := pes.createDefinitionFromTenantConfig(nil, config)
+	assert.NoError(t, err)
+	assert.Len(t, definition.InputDescriptors, 3)
+	assert.Equal(t, coreModels.MechanismAuthnFactor, definition.InputDescriptors[2].Mechanism)
+	assert.Equal(t, coreModels.PEVersion1, definition.Version())
+}
+
+func TestPresExchangeService_CredentialTrustLevel(t *testing.T) {
+	pes := NewPresentationExchangeService(nil, nil, &mockValidator{}, nil, nil, nil, nil, false).(*peService)
+
+	descriptor, err := pes.buildCredentialInputDescriptor(nil, coreModels.CredentialRequest{Type: "emailCredential", Mandatory: true, TrustLevel: 2}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, descriptor.TrustLevel)
+	//Trusted issuers are checked when the credential is submitted, instead of being fixed in the definition
+	assert.Len(t, descriptor.Constraints.Fields, 1)
+}
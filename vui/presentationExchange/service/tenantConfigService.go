@@ -0,0 +1,72 @@
+package service
+
+import (
+	"github.com/gataca-io/vui-core/log"
+	coreModels "github.com/gataca-io/vui-core/models"
+	presentationexchange "github.com/gataca-io/vui-core/vui/presentationExchange"
+	"github.com/labstack/echo/v4"
+)
+
+type tenantConfigService struct {
+	configRepository presentationexchange.TenantDao
+}
+
+func NewTenantConfigService(configRepository presentationexchange.TenantDao) presentationexchange.TenantConfigService {
+	return &tenantConfigService{
+		configRepository: configRepository,
+	}
+}
+
+func (tcs *tenantConfigService) GetTenantConfig(c echo.Context, tenant string) (*coreModels.TenantConfig, error) {
+	config, err := tcs.configRepository.GetTenantConfig(c, tenant)
+	if err != nil {
+		log.CErrorf(c, "Cannot retrieve config of tenant %s from database", tenant, err)
+		return nil, err
+	}
+	return config, nil
+}
+
+func (tcs *tenantConfigService) CreateConfig(c echo.Context, config *coreModels.TenantConfig) error {
+	err := lintConfig(c, config)
+	if err != nil {
+		return err
+	}
+	err = tcs.configRepository.CreateConfig(c, config)
+	if err != nil {
+		log.CError(c, "Cannot save tenant config in database", err)
+	}
+	return err
+}
+
+func (tcs *tenantConfigService) UpdateConfig(c echo.Context, config *coreModels.TenantConfig) error {
+	err := lintConfig(c, config)
+	if err != nil {
+		return err
+	}
+	err = tcs.configRepository.UpdateConfig(c, config)
+	if err != nil {
+		log.CError(c, "Cannot update tenant config in database", err)
+	}
+	return err
+}
+
+func (tcs *tenantConfigService) DeleteConfig(c echo.Context, tenant string) error {
+	err := tcs.configRepository.DeleteConfig(c, tenant)
+	if err != nil {
+		log.CError(c, "Unable to delete config of tenant ", tenant, err)
+	}
+	return err
+}
+
+// lintConfig rejects configurations whose advanced definition would make valid submissions fail
+func lintConfig(c echo.Context, config *coreModels.TenantConfig) error {
+	if config == nil {
+		log.CError(c, "Missing tenant config")
+		return coreModels.ErrBadParamInput
+	}
+	issues, err := config.Lint()
+	if err != nil {
+		log.CErrorf(c, "Advanced definition of tenant %s has authoring errors: %v", config.TenantId, issues)
+	}
+	return err
+}
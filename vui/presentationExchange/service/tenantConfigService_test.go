@@ -0,0 +1,61 @@
+package service
+
+import (
+	"testing"
+
+	coreModels "github.com/gataca-io/vui-core/models"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockTenantDao struct {
+	configs map[string]*coreModels.TenantConfig
+}
+
+func (md *mockTenantDao) GetTenantConfig(c echo.Context, tenant string) (*coreModels.TenantConfig, error) {
+	config, ok := md.configs[tenant]
+	if !ok {
+		return nil, coreModels.ErrNotFound
+	}
+	return config, nil
+}
+func (md *mockTenantDao) GetTenantConfigs(c echo.Context, tenants []string) ([]coreModels.TenantConfig, error) {
+	return nil, nil
+}
+func (md *mockTenantDao) GetAllConfigs(c echo.Context) ([]coreModels.TenantConfig, error) {
+	return nil, nil
+}
+func (md *mockTenantDao) CreateConfig(c echo.Context, config *coreModels.TenantConfig) error {
+	md.configs[config.TenantId] = config
+	return nil
+}
+func (md *mockTenantDao) UpdateConfig(c echo.Context, config *coreModels.TenantConfig) error {
+	md.configs[config.TenantId] = config
+	return nil
+}
+func (md *mockTenantDao) DeleteConfig(c echo.Context, tenant string) error {
+	delete(md.configs, tenant)
+	return nil
+}
+
+func TestTenantConfigService_Lint(t *testing.T) {
+	dao := &mockTenantDao{configs: map[string]*coreModels.TenantConfig{}}
+	tcs := NewTenantConfigService(dao)
+
+	invalid := &coreModels.TenantConfig{TenantId: "my-tenant", AdvancedDefinition: createInvalidDefinition()}
+	assert.Equal(t, coreModels.ErrInvalidDefinition, tcs.CreateConfig(nil, invalid))
+	assert.Equal(t, coreModels.ErrInvalidDefinition, tcs.UpdateConfig(nil, invalid))
+	assert.Empty(t, dao.configs)
+	assert.Equal(t, coreModels.ErrBadParamInput, tcs.CreateConfig(nil, nil))
+
+	assert.NoError(t, tcs.CreateConfig(nil, &coreModels.TenantConfig{TenantId: "my-tenant"}))
+	config, err := tcs.GetTenantConfig(nil, "my-tenant")
+	assert.NoError(t, err)
+	assert.Nil(t, config.AdvancedDefinition)
+
+	//Configurations stored before being linted are rejected when creating their exchanges
+	dao.configs["my-tenant"] = invalid
+	pes := NewPresentationExchangeService(&mockPresExchangeDao{exchanges: map[string]*coreModels.PExchange{}}, nil, &mockValidator{}, dao, nil, nil, nil, false)
+	_, err = pes.CreateFromTenant(nil, "my-tenant", nil)
+	assert.Equal(t, coreModels.ErrInvalidDefinition, err)
+}